@@ -0,0 +1,218 @@
+// Copyright 2016 The Sqlcl Author. All Rights Reserved.
+//
+// -----------------------------------------------------
+
+package sqlcl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+type BatchOptions struct {
+	MaxRows   int  // rows per statement, 0 for no limit of its own
+	MaxBytes  int  // estimated statement size, default 1MB, under mysql's max_allowed_packet
+	MaxVars   int  // bound values per statement, default 999 for sqlite3 and 65535 otherwise
+	Tx        bool // insert every chunk in one transaction, stopping at the first error
+	TxOptions *sql.TxOptions
+}
+
+type BatchResult struct {
+	RowsAffected int64
+	Chunks       int           // statements run
+	Errors       []*ChunkError // chunks that failed, in order
+}
+
+// ChunkError is the failure of the statement inserting rows[Offset:Offset+Rows].
+type ChunkError struct {
+	Chunk  int
+	Offset int
+	Rows   int
+	Err    error
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("Chunk %d (rows %d-%d): %v", e.Chunk, e.Offset, e.Offset+e.Rows-1, e.Err)
+}
+
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (s *Server) BatchInsert(table string, columns []string, rows [][]interface{}) (*BatchResult, error) {
+	return s.BatchInsertContext(context.Background(), table, columns, rows)
+}
+
+func (s *Server) BatchInsertContext(ctx context.Context, table string, columns []string, rows [][]interface{}) (*BatchResult, error) {
+	return s.BatchInsertOptions(ctx, BatchOptions{}, table, columns, rows)
+}
+
+// BatchInsertOptions inserts rows, each holding one value per column, with
+// as few multi-row INSERT statements as o allows. Values are bound as they
+// are.
+//
+// Without o.Tx a failed chunk does not stop the later ones. Either way the
+// returned error is the first ChunkError, and rst lists all of them.
+func (s *Server) BatchInsertOptions(ctx context.Context, o BatchOptions, table string, columns []string, rows [][]interface{}) (*BatchResult, error) {
+
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("No Columns")
+	}
+
+	for _, name := range append([]string{table}, columns...) {
+		if err := checkIdent(s.dialect, name); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, row := range rows {
+
+		if len(row) != len(columns) {
+			return nil, fmt.Errorf("Invalid Row %d: %d values for %d columns", i, len(row), len(columns))
+		}
+	}
+
+	o = o.withDefaults(s.dialect)
+	rst := &BatchResult{}
+
+	if !o.Tx {
+		return rst, batchInsert(ctx, s.db, s.dialect, o, table, columns, rows, 0, rst)
+	}
+
+	err := s.WithTxOptions(ctx, o.TxOptions, func(tx *Tx) error {
+		return batchInsert(ctx, tx.tx, s.dialect, o, table, columns, rows, 0, rst)
+	})
+	if err != nil {
+		rst.RowsAffected = 0
+	}
+
+	return rst, err
+}
+
+// batchInsert runs the chunks of rows on db, adding to rst, which may hold
+// the chunks of earlier calls. first is the position of rows[0] in the
+// whole input, for ChunkError.Offset.
+func batchInsert(ctx context.Context, db execer, d Dialect, o BatchOptions, table string, columns []string, rows [][]interface{}, first int, rst *BatchResult) error {
+
+	var (
+		err    error
+		prefix = batchPrefix(d, table, columns)
+	)
+
+	for offset := 0; offset < len(rows); {
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		n := o.chunk(len(prefix), rows[offset:])
+
+		res, cerr := db.ExecContext(ctx, batchStatement(d, prefix, len(columns), n), batchArgs(rows[offset:offset+n])...)
+		rst.Chunks++
+
+		if cerr != nil {
+
+			rst.Errors = append(rst.Errors, &ChunkError{Chunk: rst.Chunks - 1, Offset: first + offset, Rows: n, Err: cerr})
+			if err == nil {
+				err = rst.Errors[len(rst.Errors)-1]
+			}
+
+			if o.Tx {
+				return err
+			}
+		} else if affected, aerr := res.RowsAffected(); aerr == nil {
+			rst.RowsAffected += affected
+		}
+
+		offset += n
+	}
+
+	return err
+}
+
+func (o BatchOptions) withDefaults(d Dialect) BatchOptions {
+
+	if o.MaxBytes <= 0 {
+		o.MaxBytes = 1 << 20
+	}
+
+	if o.MaxVars <= 0 {
+
+		o.MaxVars = 65535
+		if d.Name() == DialectSQLite.Name() {
+			o.MaxVars = 999
+		}
+	}
+
+	return o
+}
+
+// chunk returns how many of rows the next statement takes: at least one,
+// and as many more as fit under the row, value and size limits.
+func (o BatchOptions) chunk(size int, rows [][]interface{}) int {
+
+	n := 0
+	for _, row := range rows {
+
+		rowSize := 3 * len(row)
+		for _, v := range row {
+			rowSize += valueSize(v)
+		}
+
+		if n > 0 && (size+rowSize > o.MaxBytes || (n+1)*len(row) > o.MaxVars || (o.MaxRows > 0 && n >= o.MaxRows)) {
+			break
+		}
+
+		size += rowSize
+		n++
+	}
+
+	return n
+}
+
+// valueSize estimates the bytes v takes on the wire.
+func valueSize(v interface{}) int {
+
+	switch t := v.(type) {
+	case nil:
+		return 1
+	case string:
+		return len(t) + 9
+	case []byte:
+		return len(t) + 9
+	}
+
+	return 16
+}
+
+func batchPrefix(d Dialect, table string, columns []string) string {
+
+	cols := make([]string, len(columns))
+	for i, col := range columns {
+		cols[i] = d.QuoteIdent(col)
+	}
+
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES ", d.QuoteIdent(table), strings.Join(cols, ","))
+}
+
+func batchStatement(d Dialect, prefix string, columns, rows int) string {
+
+	tuple := "(" + strings.TrimSuffix(strings.Repeat("?,", columns), ",") + ")"
+	return rebind(d, prefix+strings.TrimSuffix(strings.Repeat(tuple+",", rows), ","))
+}
+
+func batchArgs(rows [][]interface{}) []interface{} {
+
+	var args []interface{}
+	for _, row := range rows {
+		args = append(args, row...)
+	}
+
+	return args
+}
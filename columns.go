@@ -0,0 +1,344 @@
+// Copyright 2016 The Sqlcl Author. All Rights Reserved.
+//
+// -----------------------------------------------------
+
+package sqlcl
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ColumnOption narrows the columns InsertRow, InsertStruct, UpdateMap and
+// their siblings take from a map or struct.
+type ColumnOption func(*columnOptions)
+
+type columnOptions struct {
+	skipZero bool
+	omit     map[string]bool
+}
+
+// SkipZero leaves out columns whose value is nil or the zero value of its
+// type, so the database default or the current value is kept.
+func SkipZero() ColumnOption {
+	return func(o *columnOptions) {
+		o.skipZero = true
+	}
+}
+
+func Omit(columns ...string) ColumnOption {
+	return func(o *columnOptions) {
+		for _, col := range columns {
+			o.omit[col] = true
+		}
+	}
+}
+
+type columnValue struct {
+	name  string
+	value interface{}
+}
+
+func newColumnOptions(opts []ColumnOption) columnOptions {
+
+	o := columnOptions{omit: map[string]bool{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+func (o columnOptions) skip(name string, value interface{}) bool {
+
+	if o.omit[name] {
+		return true
+	}
+
+	return o.skipZero && isZero(value)
+}
+
+func isZero(value interface{}) bool {
+
+	v := reflect.ValueOf(value)
+	return !v.IsValid() || v.IsZero()
+}
+
+// mapColumnValues lists the columns of m sorted by name, so the same map
+// always builds the same statement.
+func mapColumnValues(m map[string]interface{}, o columnOptions) []columnValue {
+
+	names := make([]string, 0, len(m))
+	for k := range m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var cols []columnValue
+	for _, k := range names {
+
+		if !o.skip(k, m[k]) {
+			cols = append(cols, columnValue{name: k, value: m[k]})
+		}
+	}
+
+	return cols
+}
+
+// structColumnValues lists the columns of the struct v, or the struct v
+// points to, in field order, named as QueryInto maps them.
+func structColumnValues(v interface{}, o columnOptions) ([]columnValue, error) {
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Invalid Struct:%T", v)
+	}
+
+	var cols []columnValue
+	for _, f := range structFields(rv.Type(), nil) {
+
+		var value interface{}
+		if fv, ok := fieldValue(rv, f.index); ok {
+			value = fv.Interface()
+		}
+
+		if !o.skip(f.name, value) {
+			cols = append(cols, columnValue{name: f.name, value: value})
+		}
+	}
+
+	return cols, nil
+}
+
+// fieldValue is reflect.Value.FieldByIndex, reporting false when a nil
+// embedded struct pointer is in the way.
+func fieldValue(v reflect.Value, index []int) (reflect.Value, bool) {
+
+	for i, x := range index {
+
+		if i > 0 && v.Kind() == reflect.Ptr {
+
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+
+		v = v.Field(x)
+	}
+
+	return v, true
+}
+
+// InsertRow inserts the values of row under its keys, in key order. A
+// *QuerySet value nests a subquery; any other value is bound as it is.
+func (q *QuerySet) InsertRow(row map[string]interface{}, opts ...ColumnOption) *QuerySet {
+	return q.insertColumns(func() ([][]columnValue, error) {
+		return [][]columnValue{mapColumnValues(row, newColumnOptions(opts))}, nil
+	})
+}
+
+// InsertRows inserts every row in one statement. The columns are the keys
+// found in any row, and a row without one of them inserts NULL there;
+// with SkipZero a column is left out only when it is zero in every row.
+func (q *QuerySet) InsertRows(rows []map[string]interface{}, opts ...ColumnOption) *QuerySet {
+	return q.insertColumns(func() ([][]columnValue, error) {
+
+		var (
+			o      = newColumnOptions(opts)
+			zero   = o.skipZero
+			values = make([][]columnValue, len(rows))
+			keep   = map[string]bool{}
+		)
+
+		o.skipZero = false
+		for i, row := range rows {
+
+			values[i] = mapColumnValues(row, o)
+			for _, c := range values[i] {
+
+				if !zero || !isZero(c.value) {
+					keep[c.name] = true
+				}
+			}
+		}
+
+		for i, row := range values {
+
+			var cols []columnValue
+			for _, c := range row {
+				if keep[c.name] {
+					cols = append(cols, c)
+				}
+			}
+			values[i] = cols
+		}
+
+		return values, nil
+	})
+}
+
+// InsertStruct inserts the exported fields of v, a struct or a pointer to
+// one, named by their db tag or lower-cased name as QueryInto reads them.
+func (q *QuerySet) InsertStruct(v interface{}, opts ...ColumnOption) *QuerySet {
+	return q.insertColumns(func() ([][]columnValue, error) {
+
+		cols, err := structColumnValues(v, newColumnOptions(opts))
+		if err != nil {
+			return nil, err
+		}
+
+		return [][]columnValue{cols}, nil
+	})
+}
+
+// UpdateMap sets the columns of set, in key order, binding the values as
+// InsertRow does.
+func (q *QuerySet) UpdateMap(set map[string]interface{}, opts ...ColumnOption) *QuerySet {
+	return q.updateColumns(func() ([]columnValue, error) {
+		return mapColumnValues(set, newColumnOptions(opts)), nil
+	})
+}
+
+func (q *QuerySet) UpdateStruct(v interface{}, opts ...ColumnOption) *QuerySet {
+	return q.updateColumns(func() ([]columnValue, error) {
+		return structColumnValues(v, newColumnOptions(opts))
+	})
+}
+
+// insertColumns fills the field list and the VALUES rows. The columns are
+// read when the statement is built, so changes to the source until then
+// are picked up.
+func (q *QuerySet) insertColumns(read func() ([][]columnValue, error)) *QuerySet {
+
+	q.set[QINSERTFIELDS] = qpart{render: func(d Dialect) (string, []interface{}, error) {
+
+		rows, err := read()
+		if err != nil {
+			return "", nil, err
+		}
+
+		names := columnNames(rows)
+		if len(names) == 0 {
+			return "", nil, fmt.Errorf("No Columns")
+		}
+
+		quoted := make([]string, len(names))
+		for i, name := range names {
+
+			if quoted[i], err = quoteIdent(d, name); err != nil {
+				return "", nil, err
+			}
+		}
+
+		var (
+			sql    = fmt.Sprintf(" (%s)  %s ", strings.Join(quoted, ","), QINSERTVALUES[1:])
+			args   []interface{}
+			tuples = make([]string, len(rows))
+		)
+
+		for i, row := range rows {
+
+			byName := make(map[string]interface{}, len(row))
+			for _, c := range row {
+				byName[c.name] = c.value
+			}
+
+			texts := make([]string, len(names))
+			for j, name := range names {
+
+				var a []interface{}
+				if texts[j], a, err = dataPart(name, byName[name]).build(d); err != nil {
+					return "", nil, err
+				}
+				args = append(args, a...)
+			}
+
+			tuples[i] = "(" + strings.Join(texts, ",") + ")"
+		}
+
+		return sql + strings.Join(tuples, ",") + " ", args, nil
+	}}
+
+	delete(q.set, QINSERTVALUES)
+	return q
+}
+
+func (q *QuerySet) updateColumns(read func() ([]columnValue, error)) *QuerySet {
+
+	q.set[QUPDATESET] = qpart{render: func(d Dialect) (string, []interface{}, error) {
+
+		cols, err := read()
+		if err != nil {
+			return "", nil, err
+		}
+
+		if len(cols) == 0 {
+			return "", nil, fmt.Errorf("No Columns")
+		}
+
+		var (
+			args []interface{}
+			sets = make([]string, len(cols))
+		)
+
+		for i, c := range cols {
+
+			name, err := quoteIdent(d, c.name)
+			if err != nil {
+				return "", nil, err
+			}
+
+			text, a, err := dataPart(c.name, c.value).build(d)
+			if err != nil {
+				return "", nil, err
+			}
+
+			sets[i] = name + "=" + text
+			args = append(args, a...)
+		}
+
+		return fmt.Sprintf(" %s %s ", QUPDATESET[1:], strings.Join(sets, ",")), args, nil
+	}}
+
+	return q
+}
+
+// dataPart binds the value of column name. Param is refused, as column
+// data is never left open for execution time.
+func dataPart(name string, value interface{}) qpart {
+
+	if _, ok := value.(qplaceholder); ok {
+		return errPart(fmt.Errorf("Invalid Value:Param for column %s", name))
+	}
+
+	return valuePart(value)
+}
+
+// columnNames lists the columns of the first row in order, followed by
+// any that only later rows have.
+func columnNames(rows [][]columnValue) []string {
+
+	var (
+		names []string
+		seen  = map[string]bool{}
+	)
+
+	for _, row := range rows {
+		for _, c := range row {
+
+			if !seen[c.name] {
+				seen[c.name] = true
+				names = append(names, c.name)
+			}
+		}
+	}
+
+	return names
+}
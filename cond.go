@@ -0,0 +1,174 @@
+// Copyright 2016 The Sqlcl Author. All Rights Reserved.
+//
+// -----------------------------------------------------
+
+package sqlcl
+
+import (
+	"strings"
+)
+
+// Cond is a boolean expression for QuerySet.Where, And and Or. Conditions
+// nest through And, Or and Not, and every value in them is bound as an
+// argument, or nested as a subquery when it is a *QuerySet.
+type Cond struct {
+	p qpart
+}
+
+func compareCond(name, op string, value interface{}) Cond {
+	return Cond{p: concatPart(columnPart(name), qpart{sql: " " + op + " "}, valuePart(value))}
+}
+
+func Eq(name string, value interface{}) Cond {
+	return compareCond(name, "=", value)
+}
+
+func Neq(name string, value interface{}) Cond {
+	return compareCond(name, "!=", value)
+}
+
+func Gt(name string, value interface{}) Cond {
+	return compareCond(name, ">", value)
+}
+
+func Ge(name string, value interface{}) Cond {
+	return compareCond(name, ">=", value)
+}
+
+func Lt(name string, value interface{}) Cond {
+	return compareCond(name, "<", value)
+}
+
+func Le(name string, value interface{}) Cond {
+	return compareCond(name, "<=", value)
+}
+
+func Like(name string, value interface{}) Cond {
+	return compareCond(name, "LIKE", value)
+}
+
+// In and NotIn of no values are the constants 1=0 and 1=1.
+func In(name string, values ...interface{}) Cond {
+
+	if len(values) == 0 {
+		return Cond{p: qpart{sql: emptyIn(false)}}
+	}
+	return Cond{p: concatPart(columnPart(name), inPart(" IN %s", values))}
+}
+
+func NotIn(name string, values ...interface{}) Cond {
+
+	if len(values) == 0 {
+		return Cond{p: qpart{sql: emptyIn(true)}}
+	}
+	return Cond{p: concatPart(columnPart(name), inPart(" NOT IN %s", values))}
+}
+
+func Exists(sub *QuerySet) Cond {
+	return Cond{p: wrapPart("EXISTS %s", subPart(sub))}
+}
+
+func NotExists(sub *QuerySet) Cond {
+	return Cond{p: wrapPart("NOT EXISTS %s", subPart(sub))}
+}
+
+func FindInSet(value interface{}, name string) Cond {
+	return Cond{p: findInSetPart("%s", name, value)}
+}
+
+func IsNull(name string) Cond {
+	return Cond{p: concatPart(columnPart(name), qpart{sql: " IS NULL"})}
+}
+
+func IsNotNull(name string) Cond {
+	return Cond{p: concatPart(columnPart(name), qpart{sql: " IS NOT NULL"})}
+}
+
+// Expr wraps a hand-written condition. Its ? placeholders take args in
+// order; a Param arg, or a ? without an arg, leaves the position open for
+// execution time.
+func Expr(sql string, args ...interface{}) Cond {
+
+	p := qpart{sql: sql}
+	for _, v := range args {
+		p.args = append(p.args, v)
+	}
+
+	n := 0
+	scanPlaceholders(sql, func(int) {
+		if n++; n > len(args) {
+			p.args = append(p.args, Param)
+		}
+	})
+
+	return Cond{p: p}
+}
+
+// And is true when every condition is; with none it is always true.
+func And(conds ...Cond) Cond {
+	return joinCond(" AND ", "1=1", conds)
+}
+
+// Or is true when any condition is; with none it is always false.
+func Or(conds ...Cond) Cond {
+	return joinCond(" OR ", "1=0", conds)
+}
+
+func Not(c Cond) Cond {
+	return Cond{p: wrapPart("NOT (%s)", c.p)}
+}
+
+// joinCond parenthesizes each group it builds, so nesting renders the
+// same precedence the call tree expresses.
+func joinCond(op, empty string, conds []Cond) Cond {
+
+	if len(conds) == 0 {
+		return Cond{p: qpart{sql: empty}}
+	}
+
+	if len(conds) == 1 {
+		return conds[0]
+	}
+
+	return Cond{p: qpart{render: func(d Dialect) (string, []interface{}, error) {
+
+		var (
+			args  []interface{}
+			texts = make([]string, len(conds))
+		)
+
+		for i, c := range conds {
+
+			var (
+				a   []interface{}
+				err error
+			)
+
+			if texts[i], a, err = c.p.build(d); err != nil {
+				return "", nil, err
+			}
+			args = append(args, a...)
+		}
+
+		return "(" + strings.Join(texts, op) + ")", args, nil
+	}}}
+}
+
+// condPart renders the filter for Where, And and Or. A string is the
+// legacy column name followed by an operator method such as Eq; a Cond is
+// a complete condition.
+func condPart(keyword string, expr interface{}) (qpart, bool) {
+
+	switch e := expr.(type) {
+	case string:
+		if strings.ContainsAny(e, "=><") {
+			return qpart{}, false
+		}
+		return listPart(" "+keyword+" %s ", e, listColumn), true
+
+	case Cond:
+		return wrapPart(" "+keyword+" %s ", e.p), true
+	}
+
+	return qpart{}, false
+}
@@ -0,0 +1,81 @@
+// Copyright 2016 The Sqlcl Author. All Rights Reserved.
+//
+// -----------------------------------------------------
+
+package sqlcl
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCondSql(t *testing.T) {
+
+	var (
+		qset  = NewQuerySet()
+		qneed = strings.TrimSpace("SELECT *  FROM `users`  WHERE (`status` = ? AND (`age` > ? OR NOT ((`role` IN (?,?) AND FIND_IN_SET(?, `tags`)))) AND `deleted_at` IS NULL)   OR `id` = ?")
+	)
+
+	qset.Select("*").From("users").Where(And(
+		Eq("status", 1),
+		Or(Gt("age", 18), Not(And(In("role", "admin", "owner"), FindInSet("vip", "tags")))),
+		IsNull("deleted_at"),
+	)).Or(Eq("id", 7))
+
+	do_sql_test(qneed, qset, t)
+	do_args_test([]interface{}{1, 18, "admin", "owner", "vip", 7}, qset, t)
+
+	// ==========================================================
+	qneed = strings.TrimSpace(`SELECT *  FROM "users"  WHERE ("name" LIKE $1 OR 1=0)   AND "id"   = $2   AND ("score" >= $3 AND "score" < $4)`)
+	qset.Clear().UseDialect(DialectPostgres).Select("*").From("users").
+		Where(Or(Like("name", "a%"), Or())).And("id").Eq(3).And(And(Ge("score", 1), Lt("score", Param)))
+
+	do_sql_test(qneed, qset, t)
+
+	if _, args, _ := qset.Build(10); len(args) != 4 || args[3] != 10 {
+		t.Errorf("Args not matched. args:%v", args)
+	}
+	// ==========================================================
+	// A ? written into Expr without an arg is filled in its own place.
+	qneed = strings.TrimSpace(`SELECT *  FROM "cond"  WHERE a = $1 AND c = $2   AND "b"   = $3`)
+	qset.Clear().Select("*").From("cond").Where(Expr("a = ? AND c = ?", 3)).And("b").Eq(5)
+	do_sql_test(qneed, qset, t)
+
+	if _, args, _ := qset.Build(1); !reflect.DeepEqual(args, []interface{}{3, 1, 5}) {
+		t.Errorf("Args not matched. args:%v", args)
+	}
+}
+
+func TestCondSqlite3(t *testing.T) {
+
+	db, err := New(Config{
+		Driver:      "sqlite3",
+		Addr:        ":memory:",
+		MaxIdleConn: 1,
+		MaxConn:     1,
+	})
+	if err != nil {
+		t.Fatalf("db conn err:%s", err.Error())
+	}
+	defer db.Close()
+
+	if _, err = db.ExecString("create table cond(id integer not null primary key, a integer, b integer)"); err != nil {
+		t.Fatalf("Cond.#000 err:%v\n", err)
+	}
+
+	if _, err = db.ExecString("insert into cond values(1, 1, 1), (2, 1, 2), (3, 2, 1), (4, 2, 2)"); err != nil {
+		t.Fatalf("Cond.#000 err:%v\n", err)
+	}
+
+	// a = 1 AND (b = 2 OR id = 3) only matches id 2; without the
+	// parentheses id 3 would match too.
+	rst, err := db.Query(NewQuerySet().Select("id").From("cond").Where(And(Eq("a", 1), Or(Eq("b", 2), Eq("id", 3)))))
+	if err != nil {
+		t.Fatalf("Cond.#001 err:%v\n", err)
+	}
+
+	if len(rst.Data) != 1 || rst.Data[0].Int("id") != 2 {
+		t.Fatalf("Cond.#001 rst:%v\n", rst.Data)
+	}
+}
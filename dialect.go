@@ -0,0 +1,300 @@
+// Copyright 2016 The Sqlcl Author. All Rights Reserved.
+//
+// -----------------------------------------------------
+
+package sqlcl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect renders the parts of a statement that differ between
+// databases. QuerySet writes ? placeholders throughout and rewrites them
+// with Placeholder once the statement is complete.
+type Dialect interface {
+	Name() string
+	QuoteIdent(name string) string // each dotted segment, with quotes inside doubled
+	Placeholder(n int) string      // n counts from 1
+	Limit(offset, num uint64) string
+	FindInSet(name string) string                  // a test of one bound value against the set column name
+	Upsert(conflict, set []string) (string, error) // set holds rendered col=value assignments
+	Inserted(name string) string                   // the value the insert gave the quoted column name
+	SetOp(op SetOp) error                          // nil when op can combine selects
+	Join(kind JoinKind) error                      // nil when kind can join tables
+	InsertInto(mode InsertMode) (string, error)
+}
+
+type InsertMode int
+
+const (
+	InsertPlain   InsertMode = iota // INSERT INTO
+	InsertIgnore                    // skip rows that conflict
+	InsertReplace                   // replace rows that conflict
+)
+
+// DialectMySQL8 is mysql 8.0.31 or later, which adds INTERSECT and
+// EXCEPT. Select it with Config.Dialect.
+var (
+	DialectMySQL    Dialect = mysqlDialect{}
+	DialectMySQL8   Dialect = mysqlDialect{intersect: true}
+	DialectSQLite   Dialect = sqliteDialect{}
+	DialectPostgres Dialect = postgresDialect{}
+)
+
+type mysqlDialect struct {
+	intersect bool
+}
+
+func (mysqlDialect) Name() string {
+	return "mysql"
+}
+
+func (mysqlDialect) QuoteIdent(name string) string {
+	return quoteSegments(name, '`')
+}
+
+func (mysqlDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (mysqlDialect) Limit(offset, num uint64) string {
+	return fmt.Sprintf("LIMIT %d,%d", offset, num)
+}
+
+func (mysqlDialect) FindInSet(name string) string {
+	return fmt.Sprintf("FIND_IN_SET(?, %s)", name)
+}
+
+// Upsert ignores conflict, as mysql applies ON DUPLICATE KEY UPDATE to
+// every unique key, so an update may hit a row conflicting on any of
+// them. With nothing to update the first conflict column is assigned to
+// itself, leaving the row untouched; without that column there is no
+// clause to write, and InsertIgnoreTable is the way to skip the row.
+func (d mysqlDialect) Upsert(conflict, set []string) (string, error) {
+
+	sets := set
+	if len(sets) == 0 {
+
+		if len(conflict) == 0 {
+			return "", fmt.Errorf("%w:upsert without columns on mysql, use InsertIgnoreTable", ErrUnsupported)
+		}
+
+		col := d.QuoteIdent(conflict[0])
+		sets = []string{fmt.Sprintf("%s=%s", col, col)}
+	}
+
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ","), nil
+}
+
+func (mysqlDialect) Inserted(name string) string {
+	return fmt.Sprintf("VALUES(%s)", name)
+}
+
+func (d mysqlDialect) SetOp(op SetOp) error {
+
+	if (op == SetIntersect || op == SetExcept) && !d.intersect {
+		return fmt.Errorf("%w:%s needs mysql 8.0.31, see DialectMySQL8", ErrUnsupported, op)
+	}
+
+	return nil
+}
+
+// Join refuses FULL OUTER JOIN, which no mysql version has; a UNION of a
+// LEFT and a RIGHT JOIN gives the same rows.
+func (mysqlDialect) Join(kind JoinKind) error {
+
+	if kind == JoinFull {
+		return fmt.Errorf("%w:%s on mysql", ErrUnsupported, kind)
+	}
+
+	return nil
+}
+
+func (mysqlDialect) InsertInto(mode InsertMode) (string, error) {
+
+	switch mode {
+	case InsertIgnore:
+		return "INSERT IGNORE INTO", nil
+	case InsertReplace:
+		return "REPLACE INTO", nil
+	}
+
+	return "INSERT INTO", nil
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string {
+	return "sqlite3"
+}
+
+// QuoteIdent uses backticks, which sqlite accepts as mysql does. A
+// double-quoted name that matches no column is read as a string literal,
+// so a misspelt column would not fail.
+func (sqliteDialect) QuoteIdent(name string) string {
+	return quoteSegments(name, '`')
+}
+
+func (sqliteDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (sqliteDialect) Limit(offset, num uint64) string {
+	return fmt.Sprintf("LIMIT %d OFFSET %d", num, offset)
+}
+
+// FindInSet looks for the value with instr, not LIKE, so % and _ in it
+// are matched as they are.
+func (sqliteDialect) FindInSet(name string) string {
+	return fmt.Sprintf("instr(',' || %s || ',', ',' || ? || ',') > 0", name)
+}
+
+func (d sqliteDialect) Upsert(conflict, set []string) (string, error) {
+	return onConflict(d, conflict, set)
+}
+
+func (sqliteDialect) Inserted(name string) string {
+	return "excluded." + name
+}
+
+func (sqliteDialect) SetOp(op SetOp) error {
+	return nil
+}
+
+func (sqliteDialect) Join(kind JoinKind) error {
+	return nil
+}
+
+func (sqliteDialect) InsertInto(mode InsertMode) (string, error) {
+
+	switch mode {
+	case InsertIgnore:
+		return "INSERT OR IGNORE INTO", nil
+	case InsertReplace:
+		return "REPLACE INTO", nil
+	}
+
+	return "INSERT INTO", nil
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string {
+	return "postgres"
+}
+
+func (postgresDialect) QuoteIdent(name string) string {
+	return quoteSegments(name, '"')
+}
+
+func (postgresDialect) Placeholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+func (postgresDialect) Limit(offset, num uint64) string {
+	return fmt.Sprintf("LIMIT %d OFFSET %d", num, offset)
+}
+
+func (postgresDialect) FindInSet(name string) string {
+	return fmt.Sprintf("? = ANY(string_to_array(%s, ','))", name)
+}
+
+func (d postgresDialect) Upsert(conflict, set []string) (string, error) {
+	return onConflict(d, conflict, set)
+}
+
+func (postgresDialect) Inserted(name string) string {
+	return "excluded." + name
+}
+
+func (postgresDialect) SetOp(op SetOp) error {
+	return nil
+}
+
+func (postgresDialect) Join(kind JoinKind) error {
+	return nil
+}
+
+// InsertInto supports only plain inserts; postgres skips conflicting rows
+// with Upsert and no update columns.
+func (postgresDialect) InsertInto(mode InsertMode) (string, error) {
+
+	if mode != InsertPlain {
+		return "", fmt.Errorf("%w:insert mode %d on postgres, use Upsert", ErrUnsupported, mode)
+	}
+
+	return "INSERT INTO", nil
+}
+
+// onConflict renders the ON CONFLICT clause shared by sqlite3 and
+// postgres. Both need the conflict columns to update a row, though not
+// to skip one.
+func onConflict(d Dialect, conflict, set []string) (string, error) {
+
+	target := ""
+	if len(conflict) > 0 {
+
+		cols := make([]string, len(conflict))
+		for i, col := range conflict {
+			cols[i] = d.QuoteIdent(col)
+		}
+		target = "(" + strings.Join(cols, ",") + ") "
+	}
+
+	if len(set) == 0 {
+		return "ON CONFLICT " + target + "DO NOTHING", nil
+	}
+
+	if len(conflict) == 0 {
+		return "", fmt.Errorf("%w:upsert without conflict columns on %s", ErrUnsupported, d.Name())
+	}
+
+	return "ON CONFLICT " + target + "DO UPDATE SET " + strings.Join(set, ","), nil
+}
+
+// scanPlaceholders calls fn with the offset of every ? in s that is not
+// inside a quoted string or identifier.
+func scanPlaceholders(s string, fn func(i int)) {
+
+	var quote byte
+
+	for i := 0; i < len(s); i++ {
+
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			fn(i)
+		}
+	}
+}
+
+// rebind rewrites the ? placeholders of s in d's style.
+func rebind(d Dialect, s string) string {
+
+	if d.Placeholder(1) == "?" {
+		return s
+	}
+
+	var (
+		b    strings.Builder
+		n    = 0
+		last = 0
+	)
+
+	scanPlaceholders(s, func(i int) {
+		n++
+		b.WriteString(s[last:i])
+		b.WriteString(d.Placeholder(n))
+		last = i + 1
+	})
+	b.WriteString(s[last:])
+
+	return b.String()
+}
@@ -0,0 +1,343 @@
+// Copyright 2016 The Sqlcl Author. All Rights Reserved.
+//
+// -----------------------------------------------------
+
+package sqlcl
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDialectPostgresSql(t *testing.T) {
+
+	var (
+		qset  = NewQuerySet().UseDialect(DialectPostgres)
+		qneed = strings.TrimSpace(`SELECT *  FROM "users"  WHERE "id"   = $1   AND "name"   IN ($2,$3)   OR $4 = ANY(string_to_array("tags", ','))  LIMIT 20 OFFSET 100`)
+	)
+
+	qset.Select("*").From("users").Where("id").Eq(7).And("name").In("a", "b").OrFindInSet("x", "tags").Limit(100, 20)
+	do_sql_test(qneed, qset, t)
+	do_args_test([]interface{}{7, "a", "b", "x"}, qset, t)
+
+	// ==========================================================
+	qneed = strings.TrimSpace(`UPDATE  "users"  SET name=$1, note='what?'  WHERE "id"   = $2`)
+	qset.Clear().UpdateTable("users").UpdateSet("name=?, note='what?'").Where("id").Eq(Param)
+	do_sql_test(qneed, qset, t)
+
+	if _, args, _ := qset.Build("bob", 7); len(args) != 2 || args[0] != "bob" || args[1] != 7 {
+		t.Errorf("Args not matched. args:%v", args)
+	}
+}
+
+func TestDialectQuoteIdent(t *testing.T) {
+
+	for i, c := range []struct {
+		d          Dialect
+		name, need string
+	}{
+		{DialectMySQL, "db.table", "`db`.`table`"},
+		{DialectMySQL, "odd`name", "`odd``name`"},
+		{DialectMySQL, "`a.b`.c", "`a.b`.`c`"},
+		{DialectMySQL, "t.*", "`t`.*"},
+		{DialectPostgres, `public.say "hi"`, `"public"."say ""hi"""`},
+		{DialectSQLite, "`x`.`y`", "`x`.`y`"},
+		{DialectSQLite, `say "hi"`, "`say \"hi\"`"},
+	} {
+		if quoted := c.d.QuoteIdent(c.name); quoted != c.need {
+			t.Fatalf("QuoteIdent.#%03d quoted:%s\n", i+1, quoted)
+		}
+	}
+
+	for i, name := range []string{"", "a..b", "a.", "`open", "tab\tname", strings.Repeat("x", 65)} {
+		if err := checkIdent(DialectMySQL, name); !errors.Is(err, ErrInvalidIdent) {
+			t.Fatalf("QuoteIdent.#%03d name:%q err:%v\n", i+101, name, err)
+		}
+	}
+
+	if err := checkIdent(DialectSQLite, strings.Repeat("x", 65)); err != nil {
+		t.Fatalf("QuoteIdent.#201 err:%v\n", err)
+	}
+}
+
+func TestDialectIdentSql(t *testing.T) {
+
+	var (
+		qset  = NewQuerySet()
+		qneed = strings.TrimSpace("SELECT DISTINCT `u`.`name` AS `n`, `u`.*, COUNT(*) AS c, NULL, 'x' AS y, ?  FROM `app`.`users`  AS `u` " +
+			" INNER JOIN `app`.`teams` AS `t` ON t.id = u.team_id  WHERE `u`.`age`   > ?   AND (`u`.`id` IS NOT NULL AND u.id = t.owner_id)  " +
+			"GROUP BY `u`.`name`, LOWER(u.kind) ORDER BY `n` DESC, `u`.`id`, LENGTH(u.name) ASC")
+	)
+
+	qset.Select("DISTINCT u.name AS n, u.*, COUNT(*) AS c, NULL, 'x' AS y, ?").FromAs("app.users", "u").
+		InnerJoinAsOn("app.teams", "t", "t.id = u.team_id").
+		Where("u.age").Gt(18).And(And(IsNotNull("u.id"), Expr("u.id = t.owner_id"))).
+		GroupBy("u.name, LOWER(u.kind)").OrderBy("n DESC, u.id, LENGTH(u.name) ASC")
+	do_sql_test(qneed, qset, t)
+	if _, args, _ := qset.Build(1); !reflect.DeepEqual(args, []interface{}{1, 18}) {
+		t.Fatalf("IdentSql.#000 args:%v\n", args)
+	}
+
+	qneed = strings.TrimSpace(`SELECT "id"  FROM "app"."users"  WHERE "say ""hi"""   = $1  ORDER BY "id" DESC`)
+	qset.Clear().UseDialect(DialectPostgres).Select("id").From("app.users").Where(`"say ""hi"""`).Eq(1).OrderBy("id DESC")
+	do_sql_test(qneed, qset, t)
+
+	if _, _, err := NewQuerySet().Select("*").From("a..b").Build(); !errors.Is(err, ErrInvalidIdent) {
+		t.Fatalf("IdentSql.#001 err:%v\n", err)
+	}
+
+	if _, _, err := NewQuerySet().Select("*").FromAs("users", strings.Repeat("u", 65)).Build(); !errors.Is(err, ErrInvalidIdent) {
+		t.Fatalf("IdentSql.#002 err:%v\n", err)
+	}
+
+	// A list cannot end the statement or comment out the rest of it.
+	for i, qset := range []*QuerySet{
+		NewQuerySet().Select("*").From("it").OrderBy("id; DROP TABLE it"),
+		NewQuerySet().Select("*").From("it").GroupBy("id --"),
+		NewQuerySet().Select("id /* x */").From("it"),
+		NewQuerySet().Select("*").From("it").Where("id;").Eq(1),
+	} {
+		if _, _, err := qset.Build(); !errors.Is(err, ErrInvalidIdent) {
+			t.Fatalf("IdentSql.#%03d err:%v\n", i+3, err)
+		}
+	}
+
+	qneed = strings.TrimSpace("SELECT ';' AS sep, `a--b`  FROM `it`  WHERE `id`   = ?  GROUP BY `it`.`/*x*/`")
+	qset.Clear().UseDialect(DialectMySQL).Select("';' AS sep, `a--b`").From("it").Where("id").Eq(1).GroupBy("it.`/*x*/`")
+	do_sql_test(qneed, qset, t)
+
+	// The Expr methods take their lists as written.
+	qneed = strings.TrimSpace("SELECT id, name -- all of them\n  FROM `it`  GROUP BY id /* once */ ORDER BY CASE WHEN id = ? THEN 0 END, id")
+	qset.Clear().SelectExpr("id, name -- all of them\n").From("it").GroupByExpr("id /* once */").OrderByExpr("CASE WHEN id = ? THEN 0 END, id", 7)
+	do_sql_test(qneed, qset, t)
+	do_args_test([]interface{}{7}, qset, t)
+}
+
+func TestDialectUpsert(t *testing.T) {
+
+	for d, need := range map[Dialect]string{
+		DialectMySQL:    "ON DUPLICATE KEY UPDATE `name`=VALUES(`name`)",
+		DialectSQLite:   "ON CONFLICT (`id`) DO UPDATE SET `name`=excluded.`name`",
+		DialectPostgres: `ON CONFLICT ("id") DO UPDATE SET "name"=excluded."name"`,
+	} {
+		name := d.QuoteIdent("name")
+		if sql, err := d.Upsert([]string{"id"}, []string{name + "=" + d.Inserted(name)}); err != nil || sql != need {
+			t.Errorf("%s upsert:%s err:%v", d.Name(), sql, err)
+		}
+	}
+
+	for d, need := range map[Dialect]string{
+		DialectMySQL:    "ON DUPLICATE KEY UPDATE `id`=`id`",
+		DialectPostgres: `ON CONFLICT ("id") DO NOTHING`,
+	} {
+		if sql, err := d.Upsert([]string{"id"}, nil); err != nil || sql != need {
+			t.Errorf("%s upsert:%s err:%v", d.Name(), sql, err)
+		}
+	}
+
+	if _, _, err := NewQuerySet().InsertTable("tags").InsertRow(map[string]interface{}{"id": 1}).Upsert(nil).Build(); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("mysql upsert err:%v", err)
+	}
+
+	// Assignments follow the update columns, binding their values.
+	for d, need := range map[Dialect]string{
+		DialectMySQL:    "INSERT INTO  `tags`  (`id`,`name`)  VALUES (?,?)  ON DUPLICATE KEY UPDATE `name`=VALUES(`name`),`hits`=hits + ?,`note`=?",
+		DialectPostgres: `INSERT INTO  "tags"  ("id","name")  VALUES ($1,$2)  ON CONFLICT ("id") DO UPDATE SET "name"=excluded."name","hits"=hits + $3,"note"=$4`,
+	} {
+		qset := NewQuerySet().UseDialect(d).InsertTable("tags").InsertRow(map[string]interface{}{"id": 1, "name": "go"}).
+			UpsertSet([]string{"id"}, map[string]interface{}{"hits": Expr("hits + ?", 2), "note": "?"}, "name")
+		do_sql_test(need, qset, t)
+		do_args_test([]interface{}{1, "go", 2, "?"}, qset, t)
+	}
+
+	// Without a target sqlite3 and postgres can only skip the row.
+	for _, d := range []Dialect{DialectSQLite, DialectPostgres} {
+
+		if sql, err := d.Upsert(nil, nil); err != nil || sql != "ON CONFLICT DO NOTHING" {
+			t.Errorf("%s upsert:%s err:%v", d.Name(), sql, err)
+		}
+
+		qset := NewQuerySet().UseDialect(d).InsertTable("tags").InsertFields("id, name").InsertValues("(?,?)").Upsert(nil, "name")
+		if _, _, err := qset.Build(1, "go"); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%s upsert err:%v", d.Name(), err)
+		}
+	}
+}
+
+func TestDialectInsertSql(t *testing.T) {
+
+	for d, need := range map[Dialect][]string{
+		DialectMySQL: {
+			"INSERT IGNORE INTO `tags`  (`id`, `name`)  VALUES (?,?)",
+			"REPLACE INTO `tags`  (`id`, `name`)  VALUES (?,?)",
+			"INSERT INTO  `tags`  (`id`, `name`)  VALUES (?,?)  ON DUPLICATE KEY UPDATE `name`=VALUES(`name`)",
+		},
+		DialectSQLite: {
+			"INSERT OR IGNORE INTO `tags`  (`id`, `name`)  VALUES (?,?)",
+			"REPLACE INTO `tags`  (`id`, `name`)  VALUES (?,?)",
+			"INSERT INTO  `tags`  (`id`, `name`)  VALUES (?,?)  ON CONFLICT (`id`) DO UPDATE SET `name`=excluded.`name`",
+		},
+	} {
+
+		qset := NewQuerySet().UseDialect(d)
+
+		qset.InsertIgnoreTable("tags").InsertFields("id, name").InsertValues("(?,?)")
+		do_sql_test(need[0], qset, t)
+
+		qset.Clear().ReplaceTable("tags").InsertFields("id, name").InsertValues("(?,?)")
+		do_sql_test(need[1], qset, t)
+
+		qset.Clear().InsertTable("tags").InsertFields("id, name").InsertValues("(?,?)").Upsert([]string{"id"}, "name")
+		do_sql_test(need[2], qset, t)
+	}
+
+	qset := NewQuerySet().UseDialect(DialectPostgres).InsertIgnoreTable("tags").InsertFields("id").InsertValues("(?)")
+	if _, _, err := qset.Build(1); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("Insert.#001 err:%v\n", err)
+	}
+
+	qneed := `INSERT INTO  "tags"  ("id")  VALUES ($1)  ON CONFLICT ("id") DO NOTHING`
+	qset.Clear().InsertTable("tags").InsertFields("id").InsertValues("(?)").Upsert([]string{"id"})
+	do_sql_test(qneed, qset, t)
+
+	qset.Clear().InsertTable("tags").InsertFields("id) SELECT 1; --").InsertValues("(?)")
+	if _, _, err := qset.Build(1); !errors.Is(err, ErrInvalidIdent) {
+		t.Fatalf("Insert.#002 err:%v\n", err)
+	}
+}
+
+func TestDialectSqlite3Upsert(t *testing.T) {
+
+	db, err := New(Config{
+		Driver:      "sqlite3",
+		Addr:        ":memory:",
+		MaxIdleConn: 1,
+		MaxConn:     1,
+	})
+	if err != nil {
+		t.Fatalf("db conn err:%s", err.Error())
+	}
+	defer db.Close()
+
+	if _, err = db.ExecString("create table tags(id integer not null primary key, name text, hits integer default 0)"); err != nil {
+		t.Fatalf("Upsert.#000 err:%v\n", err)
+	}
+
+	if _, err = db.ExecString("insert into tags values(1, 'go', 5), (2, 'sql', 3)"); err != nil {
+		t.Fatalf("Upsert.#000 err:%v\n", err)
+	}
+
+	for i, qset := range []*QuerySet{
+		NewQuerySet().InsertTable("tags").InsertFields("id, name").InsertValues("(?,?)").Upsert([]string{"id"}, "name"),
+		NewQuerySet().InsertIgnoreTable("tags").InsertFields("id, name").InsertValues("(?,?)"),
+		NewQuerySet().ReplaceTable("tags").InsertFields("id, name").InsertValues("(?,?)"),
+		NewQuerySet().InsertTable("tags").InsertFields("id, name").InsertValues("(?,?)").Upsert([]string{"id"}),
+	} {
+
+		if _, err = db.Exec(qset, i%2+1, fmt.Sprintf("name%d", i)); err != nil {
+			t.Fatalf("Upsert.#%03d err:%v\n", i+1, err)
+		}
+	}
+
+	rst, err := db.Query(NewQuerySet().Select("id, name, hits").From("tags").OrderBy("id"))
+	if err != nil {
+		t.Fatalf("Upsert.#005 err:%v\n", err)
+	}
+
+	// 1 was updated in place and then replaced, losing its hits; 2 kept
+	// its row through both the ignored and the do-nothing insert.
+	if len(rst.Data) != 2 || rst.Data[0].Get("name") != "name2" || rst.Data[0].Int("hits") != 0 ||
+		rst.Data[1].Get("name") != "sql" || rst.Data[1].Int("hits") != 3 {
+		t.Fatalf("Upsert.#005 rst:%v\n", rst.Data)
+	}
+
+	// An assignment can build on the row it updates.
+	upsert := NewQuerySet().InsertTable("tags").InsertRow(map[string]interface{}{"id": 2, "name": "go"}).
+		UpsertSet([]string{"id"}, map[string]interface{}{"hits": Expr("hits + ?", 10)})
+	if _, err = db.Exec(upsert); err != nil {
+		t.Fatalf("Upsert.#006 err:%v\n", err)
+	}
+
+	row, err := db.QueryRow(NewQuerySet().Select("name, hits").From("tags").Where("id").Eq(2))
+	if err != nil || row.Get("name") != "sql" || row.Int("hits") != 13 {
+		t.Fatalf("Upsert.#006 row:%v err:%v\n", row, err)
+	}
+}
+
+func TestDialectSqlite3QuoteIdent(t *testing.T) {
+
+	db, err := New(Config{
+		Driver:      "sqlite3",
+		Addr:        ":memory:",
+		MaxIdleConn: 1,
+		MaxConn:     1,
+	})
+	if err != nil {
+		t.Fatalf("db conn err:%s", err.Error())
+	}
+	defer db.Close()
+
+	if _, err = db.ExecString("create table t(id integer not null primary key, name text)"); err != nil {
+		t.Fatalf("QuoteIdent.#000 err:%v\n", err)
+	}
+
+	if _, err = db.ExecString("insert into t values(1, 'nmae')"); err != nil {
+		t.Fatalf("QuoteIdent.#000 err:%v\n", err)
+	}
+
+	// A misspelt column fails instead of reading as a string.
+	if _, err = db.Query(NewQuerySet().Select("nmae").From("t").Where("nmae").Eq("nmae")); err == nil {
+		t.Fatalf("QuoteIdent.#001 err:%v\n", err)
+	}
+
+	rst, err := db.Query(NewQuerySet().Select("name").From("t").Where("name").Eq("nmae"))
+	if err != nil || len(rst.Data) != 1 || rst.Data[0].Get("name") != "nmae" {
+		t.Fatalf("QuoteIdent.#002 rst:%v err:%v\n", rst, err)
+	}
+}
+
+func TestDialectSqlite3FindInSet(t *testing.T) {
+
+	db, err := New(Config{
+		Driver:      "sqlite3",
+		Addr:        ":memory:",
+		MaxIdleConn: 1,
+		MaxConn:     1,
+	})
+	if err != nil {
+		t.Fatalf("db conn err:%s", err.Error())
+	}
+	defer db.Close()
+
+	if _, err = db.ExecString("create table tagged(id integer not null primary key, tags text)"); err != nil {
+		t.Fatalf("FindInSet.#000 err:%v\n", err)
+	}
+
+	if _, err = db.ExecString("insert into tagged values(1, 'a,b'), (2, 'b,c'), (3, 'ab')"); err != nil {
+		t.Fatalf("FindInSet.#000 err:%v\n", err)
+	}
+
+	rst, err := db.Query(NewQuerySet().Select("id").From("tagged").WhereFindInSet("b", "tags").OrderBy("id").Limit(0, 10))
+	if err != nil {
+		t.Fatalf("FindInSet.#001 err:%v\n", err)
+	}
+
+	if len(rst.Data) != 2 || rst.Data[0].Int("id") != 1 || rst.Data[1].Int("id") != 2 {
+		t.Fatalf("FindInSet.#001 rst:%v\n", rst.Data)
+	}
+
+	// Wildcards in the value are not patterns.
+	for i, c := range []struct {
+		value string
+		rows  int
+	}{{"%", 0}, {"_", 0}, {"a_", 0}, {"ab", 1}} {
+
+		rst, err = db.Query(NewQuerySet().Select("id").From("tagged").WhereFindInSet(c.value, "tags"))
+		if err != nil || len(rst.Data) != c.rows {
+			t.Fatalf("FindInSet.#%03d value:%s rst:%v err:%v\n", i+2, c.value, rst, err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2016 The Sqlcl Author. All Rights Reserved.
+//
+// -----------------------------------------------------
+
+package sqlcl
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+)
+
+// DSNFunc builds the data source name New passes to sql.Open.
+type DSNFunc func(c Config) string
+
+type driverInfo struct {
+	dsn     DSNFunc
+	dialect Dialect
+}
+
+var (
+	driversMu sync.RWMutex
+	drivers   = map[string]driverInfo{}
+)
+
+func init() {
+	RegisterDriver("mysql", mysqlDSN, DialectMySQL)
+	RegisterDriver("sqlite3", sqliteDSN, DialectSQLite)
+	RegisterDriver("postgres", postgresDSN, DialectPostgres)
+}
+
+// RegisterDriver makes the database/sql driver registered as name
+// available to New, with dsn turning a Config into its data source name
+// and d rendering its queries (mysql when nil). Registering a name again
+// replaces the earlier entry.
+func RegisterDriver(name string, dsn DSNFunc, d Dialect) {
+
+	if dsn == nil {
+		panic("sqlcl: RegisterDriver dsn is nil for driver " + name)
+	}
+
+	if d == nil {
+		d = DialectMySQL
+	}
+
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	drivers[name] = driverInfo{dsn: dsn, dialect: d}
+}
+
+func lookupDriver(name string) (driverInfo, error) {
+
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	info, ok := drivers[name]
+	if !ok {
+		return driverInfo{}, fmt.Errorf("Unknow db driver:%s", name)
+	}
+
+	return info, nil
+}
+
+func mysqlDSN(c Config) string {
+
+	if len(c.Protocol) < 3 {
+		c.Protocol = "tcp"
+	}
+
+	return fmt.Sprintf("%s:%s@%s(%s)/%s?%s", c.User, c.Pass, c.Protocol, c.Addr, c.DbName, c.Params)
+}
+
+func sqliteDSN(c Config) string {
+	return c.Addr
+}
+
+// postgresDSN builds a URL accepted by both lib/pq and pgx. sqlcl does not
+// import a postgres driver; the application registers one as "postgres".
+func postgresDSN(c Config) string {
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.User, c.Pass),
+		Host:     c.Addr,
+		Path:     "/" + c.DbName,
+		RawQuery: c.Params,
+	}
+
+	return u.String()
+}
@@ -0,0 +1,154 @@
+// Copyright 2016 The Sqlcl Author. All Rights Reserved.
+//
+// -----------------------------------------------------
+
+package sqlcl
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestRegisterDriver(t *testing.T) {
+
+	sql.Register("sqlcl_test_sqlite3", &sqlite3.SQLiteDriver{})
+
+	var dsn string
+	RegisterDriver("sqlcl_test_sqlite3", func(c Config) string {
+		dsn = "file:" + c.DbName + "?mode=memory"
+		return dsn
+	}, DialectSQLite)
+
+	db, err := New(Config{
+		Driver:      "sqlcl_test_sqlite3",
+		DbName:      "registered",
+		MaxIdleConn: 1,
+		MaxConn:     1,
+	})
+	if err != nil {
+		t.Fatalf("db conn err:%s", err.Error())
+	}
+	defer db.Close()
+
+	if dsn != "file:registered?mode=memory" || db.Dialect() != DialectSQLite {
+		t.Fatalf("Driver.#000 dsn:%s dialect:%v", dsn, db.Dialect())
+	}
+
+	if _, err = db.ExecString("create table reg(id integer not null primary key, name text)"); err != nil {
+		t.Fatalf("Driver.#001 err:%v\n", err)
+	}
+
+	if _, err = db.Exec(NewQuerySet().InsertTable("reg").InsertFields("id,name").InsertValues("(?,?)"), 1, "alice"); err != nil {
+		t.Fatalf("Driver.#001 err:%v\n", err)
+	}
+
+	if row, err := db.QueryRow(NewQuerySet().Select("*").From("reg").Where("id").Eq(1)); err != nil || row.Get("name") != "alice" {
+		t.Fatalf("Driver.#002 row:%v err:%v\n", row, err)
+	}
+
+	if _, err = New(Config{Driver: "sqlcl_test_unknown"}); err == nil {
+		t.Fatalf("Driver.#003 expected unknown driver error")
+	}
+}
+
+// faultyDriver answers every query with four rows whose even ones hold a
+// value no Scan destination accepts.
+type faultyDriver struct{}
+
+type faultyConn struct{}
+
+type faultyStmt struct{}
+
+type faultyRows struct {
+	n int
+}
+
+func (faultyDriver) Open(string) (driver.Conn, error) {
+	return faultyConn{}, nil
+}
+
+func (faultyConn) Prepare(string) (driver.Stmt, error) {
+	return faultyStmt{}, nil
+}
+
+func (faultyConn) Close() error {
+	return nil
+}
+
+func (faultyConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("Not Supported")
+}
+
+func (faultyStmt) Close() error {
+	return nil
+}
+
+func (faultyStmt) NumInput() int {
+	return -1
+}
+
+func (faultyStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("Not Supported")
+}
+
+func (faultyStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &faultyRows{}, nil
+}
+
+func (*faultyRows) Columns() []string {
+	return []string{"id", "v"}
+}
+
+func (*faultyRows) Close() error {
+	return nil
+}
+
+func (r *faultyRows) Next(dest []driver.Value) error {
+
+	if r.n >= 4 {
+		return io.EOF
+	}
+
+	r.n++
+	dest[0], dest[1] = int64(r.n), "ok"
+	if r.n%2 == 0 {
+		dest[1] = struct{}{}
+	}
+
+	return nil
+}
+
+func TestDriverRowErrors(t *testing.T) {
+
+	sql.Register("sqlcl_test_faulty", faultyDriver{})
+	RegisterDriver("sqlcl_test_faulty", func(Config) string { return "" }, DialectSQLite)
+
+	var (
+		rerr *RowError
+		qset = NewQuerySet().Select("id, v").From("foo")
+	)
+
+	for i, lenient := range []bool{false, true} {
+
+		db, err := New(Config{Driver: "sqlcl_test_faulty", LenientRows: lenient})
+		if err != nil {
+			t.Fatalf("db conn err:%s", err.Error())
+		}
+		defer db.Close()
+
+		rst, err := db.Query(qset)
+
+		if !lenient && (!errors.As(err, &rerr) || rerr.Index != 1 || len(rst.Data) != 1) {
+			t.Fatalf("RowErrors.#%03d rst:%v err:%v\n", i+1, rst, err)
+		}
+
+		if lenient && (err != nil || len(rst.Data) != 2 || rst.Data[1].Get("id") != "3" || len(rst.Errors) != 2 || rst.Errors[1].Index != 3) {
+			t.Fatalf("RowErrors.#%03d rst:%v err:%v\n", i+1, rst, err)
+		}
+	}
+}
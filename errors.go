@@ -0,0 +1,119 @@
+// Copyright 2016 The Sqlcl Author. All Rights Reserved.
+//
+// -----------------------------------------------------
+
+package sqlcl
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// The first four keep the text earlier releases returned, so callers
+// still matching on err.Error() are not broken. ErrNoTransaction and
+// ErrNoStatement share theirs; errors.Is tells them apart.
+var (
+	ErrNoRows        = errors.New("Not Found")
+	ErrNoArgs        = errors.New("No Args")
+	ErrNoTransaction = errors.New("Client Error")
+	ErrNoStatement   = errors.New("Client Error")
+	ErrUnsupported   = errors.New("Unsupported By Dialect")
+	ErrInvalidCursor = errors.New("Invalid Cursor")
+	ErrInvalidIdent  = errors.New("Invalid Identifier")
+)
+
+type ErrorKind int
+
+const (
+	KindUnknown ErrorKind = iota
+	KindDuplicateKey
+	KindForeignKey
+	KindNotNull
+	KindDeadlock
+	KindLockTimeout
+	KindConnectionLost
+	KindSyntax
+)
+
+var errorKindNames = map[ErrorKind]string{
+	KindUnknown:        "unknown",
+	KindDuplicateKey:   "duplicate key",
+	KindForeignKey:     "foreign key violation",
+	KindNotNull:        "not-null violation",
+	KindDeadlock:       "deadlock",
+	KindLockTimeout:    "lock timeout",
+	KindConnectionLost: "connection lost",
+	KindSyntax:         "syntax error",
+}
+
+func (k ErrorKind) String() string {
+	return errorKindNames[k]
+}
+
+var mysqlErrorKinds = map[uint16]ErrorKind{
+	1062: KindDuplicateKey,   // ER_DUP_ENTRY
+	1586: KindDuplicateKey,   // ER_DUP_ENTRY_WITH_KEY_NAME
+	1216: KindForeignKey,     // ER_NO_REFERENCED_ROW
+	1217: KindForeignKey,     // ER_ROW_IS_REFERENCED
+	1451: KindForeignKey,     // ER_ROW_IS_REFERENCED_2
+	1452: KindForeignKey,     // ER_NO_REFERENCED_ROW_2
+	1048: KindNotNull,        // ER_BAD_NULL_ERROR
+	1364: KindNotNull,        // ER_NO_DEFAULT_FOR_FIELD
+	1213: KindDeadlock,       // ER_LOCK_DEADLOCK
+	1205: KindLockTimeout,    // ER_LOCK_WAIT_TIMEOUT
+	1053: KindConnectionLost, // ER_SERVER_SHUTDOWN
+	2006: KindConnectionLost, // CR_SERVER_GONE_ERROR
+	2013: KindConnectionLost, // CR_SERVER_LOST
+	1064: KindSyntax,         // ER_PARSE_ERROR
+	1149: KindSyntax,         // ER_SYNTAX_ERROR
+}
+
+var sqliteErrorKinds = map[int]ErrorKind{
+	5:    KindLockTimeout,  // SQLITE_BUSY
+	6:    KindLockTimeout,  // SQLITE_LOCKED
+	787:  KindForeignKey,   // SQLITE_CONSTRAINT_FOREIGNKEY
+	1299: KindNotNull,      // SQLITE_CONSTRAINT_NOTNULL
+	1555: KindDuplicateKey, // SQLITE_CONSTRAINT_PRIMARYKEY
+	2067: KindDuplicateKey, // SQLITE_CONSTRAINT_UNIQUE
+}
+
+// Classify maps a mysql or sqlite3 driver error, possibly wrapped, to the
+// kind of failure it reports. Errors it does not recognize are
+// KindUnknown.
+func Classify(err error) ErrorKind {
+
+	if err == nil {
+		return KindUnknown
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return KindConnectionLost
+	}
+
+	var me *mysql.MySQLError
+	if errors.As(err, &me) {
+		return mysqlErrorKinds[me.Number]
+	}
+
+	if code, extended, ok := sqliteErrorCode(err); ok {
+
+		if kind, ok := sqliteErrorKinds[extended]; ok {
+			return kind
+		}
+
+		if kind, ok := sqliteErrorKinds[code]; ok {
+			return kind
+		}
+
+		// SQLITE_ERROR covers every prepare failure; only the message
+		// tells a syntax error apart.
+		if code == 1 && strings.Contains(err.Error(), "syntax error") {
+			return KindSyntax
+		}
+	}
+
+	return KindUnknown
+}
@@ -0,0 +1,422 @@
+// Copyright 2016 The Sqlcl Author. All Rights Reserved.
+//
+// -----------------------------------------------------
+
+package sqlcl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// listKind is what a caller-written list passed to quoteList holds.
+type listKind int
+
+const (
+	listColumn listKind = iota // a single column, as in Where("id")
+	listSelect                 // columns with optional AS aliases, "t.*" or expressions
+	listGroup                  // columns or expressions
+	listOrder                  // columns with optional ASC or DESC, or expressions
+)
+
+// identKeywords are bare words that read as names but are not columns.
+var identKeywords = map[string]bool{
+	"NULL":              true,
+	"TRUE":              true,
+	"FALSE":             true,
+	"DEFAULT":           true,
+	"CURRENT_DATE":      true,
+	"CURRENT_TIME":      true,
+	"CURRENT_TIMESTAMP": true,
+	"CURRENT_USER":      true,
+	"LOCALTIME":         true,
+	"LOCALTIMESTAMP":    true,
+}
+
+// splitIdent splits a dotted name into its segments. A segment enclosed
+// in quote, with the quote doubled inside it, is taken as written, so it
+// may hold dots; a zero quote disables that. It reports false for an
+// empty segment or an unterminated quote.
+func splitIdent(name string, quote byte) ([]string, bool) {
+
+	var segs []string
+
+	for i := 0; ; {
+
+		var seg strings.Builder
+
+		if quote != 0 && i < len(name) && name[i] == quote {
+
+			closed := false
+			for i++; i < len(name); i++ {
+
+				if name[i] != quote {
+					seg.WriteByte(name[i])
+					continue
+				}
+
+				if i+1 < len(name) && name[i+1] == quote {
+					seg.WriteByte(quote)
+					i++
+					continue
+				}
+
+				closed = true
+				i++
+				break
+			}
+
+			if !closed || (i < len(name) && name[i] != '.') {
+				return nil, false
+			}
+		} else {
+
+			for ; i < len(name) && name[i] != '.'; i++ {
+				seg.WriteByte(name[i])
+			}
+
+			if seg.Len() == 0 {
+				return nil, false
+			}
+		}
+
+		segs = append(segs, seg.String())
+
+		if i >= len(name) {
+			return segs, true
+		}
+		i++
+	}
+}
+
+// quoteSegments quotes every dotted segment of name with quote, doubling
+// the quote characters inside it. A last segment of "*" is kept bare.
+func quoteSegments(name string, quote byte) string {
+
+	segs, ok := splitIdent(name, quote)
+	if !ok {
+		segs = []string{name}
+	}
+
+	q := string(quote)
+	for i, seg := range segs {
+
+		if seg == "*" && i == len(segs)-1 && i > 0 {
+			continue
+		}
+
+		segs[i] = q + strings.ReplaceAll(seg, q, q+q) + q
+	}
+
+	return strings.Join(segs, ".")
+}
+
+// identQuote returns the character d encloses names in, or 0 when it
+// does not quote them.
+func identQuote(d Dialect) byte {
+
+	q := d.QuoteIdent("a")
+	if len(q) == 3 && q[0] == q[2] {
+		return q[0]
+	}
+
+	return 0
+}
+
+// identMaxLen is the longest segment d accepts, 0 for no limit.
+func identMaxLen(d Dialect) int {
+
+	switch d.Name() {
+	case DialectMySQL.Name():
+		return 64
+	case DialectPostgres.Name():
+		return 63
+	}
+
+	return 0
+}
+
+// checkIdent reports whether name is a table or column name, optionally
+// qualified with dots, that d can express.
+func checkIdent(d Dialect, name string) error {
+
+	segs, ok := splitIdent(name, identQuote(d))
+	if !ok {
+		return fmt.Errorf("%w:%q", ErrInvalidIdent, name)
+	}
+
+	max := identMaxLen(d)
+	for _, seg := range segs {
+
+		if max > 0 && len(seg) > max {
+			return fmt.Errorf("%w:%q longer than %d", ErrInvalidIdent, seg, max)
+		}
+
+		for i := 0; i < len(seg); i++ {
+
+			if seg[i] < 0x20 || seg[i] == 0x7f {
+				return fmt.Errorf("%w:%q", ErrInvalidIdent, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// quoteIdent validates name and quotes it for d.
+func quoteIdent(d Dialect, name string) (string, error) {
+
+	if err := checkIdent(d, name); err != nil {
+		return "", err
+	}
+
+	return d.QuoteIdent(name), nil
+}
+
+// identPart renders name, validated and quoted, into the %s of format.
+func identPart(format, name string) qpart {
+	return qpart{render: func(d Dialect) (string, []interface{}, error) {
+
+		text, err := quoteIdent(d, name)
+		if err != nil {
+			return "", nil, err
+		}
+
+		return fmt.Sprintf(format, text), nil, nil
+	}}
+}
+
+// listPart renders a caller-written list into the %s of format, with the
+// plain names in it quoted by quoteList. Like rawPart, every ? in it is
+// left open for execution time.
+func listPart(format, list string, kind listKind) qpart {
+
+	raw := rawPart(fmt.Sprintf(format, list))
+
+	return qpart{render: func(d Dialect) (string, []interface{}, error) {
+
+		text, err := quoteList(d, list, kind)
+		if err != nil {
+			return "", nil, err
+		}
+
+		return fmt.Sprintf(format, text), raw.args, nil
+	}}
+}
+
+// columnPart renders a single column name, quoted unless it is an
+// expression.
+func columnPart(name string) qpart {
+	return listPart("%s", name, listColumn)
+}
+
+// quoteList quotes the items of list that are plain names, as "t.id",
+// "id AS n" in a select or "id DESC" in an order, and keeps every other
+// item as written, so expressions, literals and placeholders still work.
+// The spacing of list is kept.
+func quoteList(d Dialect, list string, kind listKind) (string, error) {
+
+	if err := checkList(list); err != nil {
+		return "", err
+	}
+
+	items := []string{list}
+	if kind != listColumn {
+		items = splitList(list)
+	}
+
+	for i, item := range items {
+
+		var (
+			core   = strings.TrimSpace(item)
+			lead   = item[:strings.Index(item, core)]
+			trail  = item[len(lead)+len(core):]
+			prefix = ""
+		)
+
+		if i == 0 && kind == listSelect {
+
+			for _, kw := range []string{"DISTINCT", "ALL"} {
+
+				if len(core) > len(kw) && strings.EqualFold(core[:len(kw)], kw) && isSpace(core[len(kw)]) {
+					prefix = core[:len(kw)+1]
+					core = strings.TrimLeft(core[len(kw):], " \t\r\n")
+					break
+				}
+			}
+		}
+
+		text, err := quoteItem(d, core, kind)
+		if err != nil {
+			return "", err
+		}
+
+		items[i] = lead + prefix + text + trail
+	}
+
+	return strings.Join(items, ","), nil
+}
+
+// quoteItem quotes one list item when it is a plain name, with the alias
+// or direction its kind allows.
+func quoteItem(d Dialect, item string, kind listKind) (string, error) {
+
+	quote := identQuote(d)
+
+	n := scanIdent(item, quote, kind == listSelect)
+	if n == 0 || (n < len(item) && !isSpace(item[n])) {
+		return item, nil
+	}
+
+	var (
+		name = item[:n]
+		rest = strings.Fields(item[n:])
+		tail = ""
+	)
+
+	if identKeywords[strings.ToUpper(name)] {
+		return item, nil
+	}
+
+	switch {
+	case len(rest) == 0:
+	case kind == listSelect && len(rest) == 2 && strings.EqualFold(rest[0], "AS") && scanIdent(rest[1], quote, false) == len(rest[1]):
+
+		alias, err := quoteIdent(d, rest[1])
+		if err != nil {
+			return "", err
+		}
+		tail = " " + rest[0] + " " + alias
+
+	case kind == listOrder && len(rest) == 1 && (strings.EqualFold(rest[0], "ASC") || strings.EqualFold(rest[0], "DESC")):
+		tail = " " + rest[0]
+
+	default:
+		return item, nil
+	}
+
+	text, err := quoteIdent(d, name)
+	if err != nil {
+		return "", err
+	}
+
+	return text + tail, nil
+}
+
+// scanIdent returns the length of the dotted name at the start of s, made
+// of bare words and segments enclosed in quote, or 0 when there is none.
+// With star the name may end in "*", as in "t.*", or be just "*".
+func scanIdent(s string, quote byte, star bool) int {
+
+	for i := 0; ; {
+
+		switch {
+		case star && i < len(s) && s[i] == '*':
+			i++
+			if i == 1 {
+				return 0 // a lone * is left as written
+			}
+			return i
+
+		case quote != 0 && i < len(s) && s[i] == quote:
+			for i++; ; i++ {
+
+				if i >= len(s) {
+					return 0
+				}
+
+				if s[i] == quote {
+
+					if i+1 < len(s) && s[i+1] == quote {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			i++
+
+		case i < len(s) && isIdentStart(s[i]):
+			for i++; i < len(s) && isIdentPart(s[i]); i++ {
+			}
+
+		default:
+			return 0
+		}
+
+		if i >= len(s) || s[i] != '.' {
+			return i
+		}
+		i++
+	}
+}
+
+// checkList rejects a list holding, outside quotes, a ; or the start of
+// a comment, either of which would cut the statement short. A list that
+// needs them goes through SelectExpr and its kin.
+func checkList(list string) error {
+
+	var quote byte
+
+	for i := 0; i < len(list); i++ {
+
+		c := list[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == ';', strings.HasPrefix(list[i:], "--"), strings.HasPrefix(list[i:], "/*"):
+			return fmt.Errorf("%w:%q", ErrInvalidIdent, list)
+		}
+	}
+
+	return nil
+}
+
+// splitList splits s at the commas outside parentheses and quotes.
+func splitList(s string) []string {
+
+	var (
+		items []string
+		depth = 0
+		quote byte
+		last  = 0
+	)
+
+	for i := 0; i < len(s); i++ {
+
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			items = append(items, s[last:i])
+			last = i + 1
+		}
+	}
+
+	return append(items, s[last:])
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || c == '$' || (c >= '0' && c <= '9')
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
@@ -0,0 +1,179 @@
+// Copyright 2016 The Sqlcl Author. All Rights Reserved.
+//
+// -----------------------------------------------------
+
+package sqlcl
+
+import (
+	"context"
+	"database/sql"
+)
+
+// RowIter reads a result one row at a time instead of collecting it into
+// a Result, so only the current row is held in memory. It must be closed
+// unless Next has returned false.
+type RowIter struct {
+	rows     *sql.Rows
+	columns  []string
+	values   []sql.RawBytes
+	row_dest []interface{}
+	row      *RowColumn
+	ordered  []string
+	index    int
+	err      error
+}
+
+func newRowIter(rows *sql.Rows) (*RowIter, error) {
+
+	columns, err := rows.Columns()
+	if err != nil {
+		rows.Close()
+		return nil, err
+	}
+
+	it := &RowIter{
+		rows:     rows,
+		columns:  columns,
+		values:   make([]sql.RawBytes, len(columns)),
+		row_dest: make([]interface{}, len(columns)),
+	}
+
+	for i := range it.values {
+		it.row_dest[i] = &it.values[i]
+	}
+
+	return it, nil
+}
+
+// Next advances to the next row. It returns false at the end of the result
+// or on an error, which Err then reports as a RowError, and closes the
+// iterator.
+func (it *RowIter) Next() bool {
+
+	if it.err != nil || !it.rows.Next() {
+
+		if err := it.rows.Err(); err != nil && it.err == nil {
+			it.err = &RowError{Index: it.index, Err: err}
+		}
+
+		it.row, it.ordered = nil, nil
+		it.Close()
+		return false
+	}
+
+	if err := it.rows.Scan(it.row_dest...); err != nil {
+		it.err = &RowError{Index: it.index, Err: err}
+		it.row, it.ordered = nil, nil
+		it.Close()
+		return false
+	}
+
+	it.index++
+	it.row, it.ordered = rowColumn(it.columns, it.values)
+	return true
+}
+
+func (it *RowIter) Row() *RowColumn {
+	return it.row
+}
+
+// Values returns the current row in column order.
+func (it *RowIter) Values() []string {
+	return it.ordered
+}
+
+func (it *RowIter) Columns() []string {
+	return it.columns
+}
+
+func (it *RowIter) Err() error {
+	return it.err
+}
+
+func (it *RowIter) Close() error {
+	return it.rows.Close()
+}
+
+// each calls fn with every row of it, stopping at the first error fn
+// returns.
+func (it *RowIter) each(fn func(*RowColumn) error) error {
+
+	defer it.Close()
+
+	for it.Next() {
+
+		if err := fn(it.Row()); err != nil {
+			return err
+		}
+	}
+
+	return it.Err()
+}
+
+func (s *Server) QueryIter(q *QuerySet, args ...interface{}) (*RowIter, error) {
+	return s.QueryIterContext(context.Background(), q, args...)
+}
+
+func (s *Server) QueryIterContext(ctx context.Context, q *QuerySet, args ...interface{}) (*RowIter, error) {
+
+	sql, args, err := q.bind(s.dialect, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newRowIter(rows)
+}
+
+// QueryEach calls fn with each row as it is read. An error from fn stops
+// the query and is returned.
+func (s *Server) QueryEach(q *QuerySet, fn func(*RowColumn) error, args ...interface{}) error {
+	return s.QueryEachContext(context.Background(), q, fn, args...)
+}
+
+func (s *Server) QueryEachContext(ctx context.Context, q *QuerySet, fn func(*RowColumn) error, args ...interface{}) error {
+
+	it, err := s.QueryIterContext(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+
+	return it.each(fn)
+}
+
+func (t *Tx) QueryIter(q *QuerySet, args ...interface{}) (*RowIter, error) {
+	return t.QueryIterContext(context.Background(), q, args...)
+}
+
+func (t *Tx) QueryIterContext(ctx context.Context, q *QuerySet, args ...interface{}) (*RowIter, error) {
+
+	sql, args, err := q.bind(t.dialect, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := t.tx.QueryContext(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newRowIter(rows)
+}
+
+func (t *Tx) QueryEach(q *QuerySet, fn func(*RowColumn) error, args ...interface{}) error {
+	return t.QueryEachContext(context.Background(), q, fn, args...)
+}
+
+func (t *Tx) QueryEachContext(ctx context.Context, q *QuerySet, fn func(*RowColumn) error, args ...interface{}) error {
+
+	it, err := t.QueryIterContext(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+
+	return it.each(fn)
+}
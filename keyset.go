@@ -0,0 +1,460 @@
+// Copyright 2016 The Sqlcl Author. All Rights Reserved.
+//
+// -----------------------------------------------------
+
+package sqlcl
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SortKey is one column of a keyset ordering. Column is written into the
+// statement as it is and read back from the result by its name after the
+// last dot, so "t.id" is read as "id"; it must be selected.
+type SortKey struct {
+	Column string
+	Desc   bool
+}
+
+// Keyset pages through a query by the values of its sort keys instead of
+// an offset, so every page costs the same however deep it is. The keys
+// must not be NULL, and together they must be unique, e.g. by ending
+// with the primary key, or rows sharing a key are skipped. The cursors
+// keep the key values as the driver typed them, and bind them back so.
+type Keyset struct {
+	Keys   []SortKey
+	Limit  int    // rows per page
+	Secret []byte // signs the cursors, so a client cannot forge one
+}
+
+// KeysetPage is one page of a Keyset query. Next and Prev are the cursors
+// of the pages after and before it, empty when there is none.
+type KeysetPage struct {
+	*Result
+	Next string
+	Prev string
+}
+
+type seekCursor struct {
+	Values []seekValue `json:"v"`
+	Back   bool        `json:"b,omitempty"`
+	args   []interface{}
+}
+
+// seekValue is one key value of a cursor, as text tagged with its type:
+// i int64, u uint64, f float64, b bool, s string, x []byte in base64, t
+// time.Time in RFC 3339, and n for NULL.
+type seekValue struct {
+	Type  string `json:"t"`
+	Value string `json:"v,omitempty"`
+}
+
+// Seek runs the page of q that cursor points to, or the first page when
+// cursor is empty. q must not set ORDER BY or LIMIT, which Seek adds.
+func (s *Server) Seek(q *QuerySet, k Keyset, cursor string, args ...interface{}) (*KeysetPage, error) {
+	return s.SeekContext(context.Background(), q, k, cursor, args...)
+}
+
+func (s *Server) SeekContext(ctx context.Context, q *QuerySet, k Keyset, cursor string, args ...interface{}) (*KeysetPage, error) {
+
+	sq, c, err := k.query(q, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	sql, args, err := sq.bind(s.dialect, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	rst, raw, err := scanRows(rows, s.lenientRows, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return k.page(rst, raw, c, cursor != "")
+}
+
+func (t *Tx) Seek(q *QuerySet, k Keyset, cursor string, args ...interface{}) (*KeysetPage, error) {
+	return t.SeekContext(context.Background(), q, k, cursor, args...)
+}
+
+func (t *Tx) SeekContext(ctx context.Context, q *QuerySet, k Keyset, cursor string, args ...interface{}) (*KeysetPage, error) {
+
+	sq, c, err := k.query(q, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	sql, args, err := sq.bind(t.dialect, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := t.tx.QueryContext(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	rst, raw, err := scanRows(rows, t.lenientRows, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return k.page(rst, raw, c, cursor != "")
+}
+
+// query rewrites a copy of q into the seek form for cursor:
+//
+//	WHERE (...) AND (a, b) > (?, ?) ORDER BY a, b LIMIT n+1
+//
+// The extra row tells whether another page follows. A backward cursor
+// flips every comparison and direction, and page puts the rows back in
+// order.
+func (k Keyset) query(q *QuerySet, cursor string) (*QuerySet, seekCursor, error) {
+
+	var c seekCursor
+
+	if len(k.Keys) == 0 {
+		return nil, c, fmt.Errorf("No Sort Keys")
+	}
+
+	if k.Limit <= 0 {
+		return nil, c, fmt.Errorf("Invalid Limit:%d", k.Limit)
+	}
+
+	if len(k.Secret) == 0 {
+		return nil, c, fmt.Errorf("No Cursor Secret")
+	}
+
+	if len(q.compounds) > 0 {
+		return nil, c, fmt.Errorf("Keyset Of Compound Select")
+	}
+
+	sq := q.clone()
+
+	if cursor != "" {
+
+		var err error
+		if c, err = k.decode(cursor); err != nil {
+			return nil, c, err
+		}
+
+		sq.filters = []qpart{seekFilter(q.filters, k.seekPart(c))}
+	}
+
+	order := make([]string, len(k.Keys))
+	for i, key := range k.Keys {
+
+		order[i] = key.Column
+		if key.Desc != c.Back {
+			order[i] += " DESC"
+		}
+	}
+
+	sq.OrderBy(strings.Join(order, ", "))
+	sq.Limit(0, uint64(k.Limit)+1)
+
+	return sq, c, nil
+}
+
+// seekPart selects the rows past the cursor values. Keys sorted the same
+// way compare as one row value; mixed directions expand to
+// a > ? OR (a = ? AND b < ?) and so on.
+func (k Keyset) seekPart(c seekCursor) qpart {
+	return qpart{render: func(d Dialect) (string, []interface{}, error) {
+
+		var (
+			args    []interface{}
+			same    = true
+			columns = make([]string, len(k.Keys))
+		)
+
+		for i, key := range k.Keys {
+
+			var err error
+			if columns[i], err = quoteList(d, key.Column, listColumn); err != nil {
+				return "", nil, err
+			}
+
+			same = same && key.Desc == k.Keys[0].Desc
+		}
+
+		op := func(key SortKey) string {
+
+			if key.Desc != c.Back {
+				return "<"
+			}
+			return ">"
+		}
+
+		if same {
+
+			for i := range k.Keys {
+				args = append(args, c.args[i])
+			}
+
+			if len(k.Keys) == 1 {
+				return fmt.Sprintf("%s %s ?", columns[0], op(k.Keys[0])), args, nil
+			}
+
+			marks := strings.TrimSuffix(strings.Repeat("?, ", len(k.Keys)), ", ")
+			return fmt.Sprintf("(%s) %s (%s)", strings.Join(columns, ", "), op(k.Keys[0]), marks), args, nil
+		}
+
+		terms := make([]string, len(k.Keys))
+		for i, key := range k.Keys {
+
+			var eqs []string
+			for j := 0; j < i; j++ {
+				eqs = append(eqs, columns[j]+" = ?")
+				args = append(args, c.args[j])
+			}
+
+			terms[i] = strings.Join(append(eqs, fmt.Sprintf("%s %s ?", columns[i], op(key))), " AND ")
+			if i > 0 {
+				terms[i] = "(" + terms[i] + ")"
+			}
+			args = append(args, c.args[i])
+		}
+
+		return "(" + strings.Join(terms, " OR ") + ")", args, nil
+	}}
+}
+
+// seekFilter puts the WHERE clause of filters, if there is one, in
+// parentheses and adds seek to it with AND.
+func seekFilter(filters []qpart, seek qpart) qpart {
+	return qpart{render: func(d Dialect) (string, []interface{}, error) {
+
+		where, err := renderParts(d, filters, " ")
+		if err != nil {
+			return "", nil, err
+		}
+
+		cond, cargs, err := seek.build(d)
+		if err != nil {
+			return "", nil, err
+		}
+
+		text := strings.TrimSpace(where.sql)
+		if text == "" {
+			return fmt.Sprintf(" %s %s ", QWHERE[1:], cond), cargs, nil
+		}
+
+		if !strings.HasPrefix(text, QWHERE[1:]+" ") {
+			return "", nil, fmt.Errorf("Invalid Where:%s", text)
+		}
+
+		text = strings.TrimSpace(strings.TrimPrefix(text, QWHERE[1:]))
+		args := append(append([]interface{}{}, where.args...), cargs...)
+
+		return fmt.Sprintf(" %s (%s) AND %s ", QWHERE[1:], text, cond), args, nil
+	}}
+}
+
+// page cuts rst, which holds up to Limit+1 rows, to the page and sets its
+// cursors from the first and last rows of raw, the same rows as the
+// driver typed them. An empty page keeps the cursor values, so it can
+// still be left the way it was entered.
+func (k Keyset) page(rst *Result, raw [][]interface{}, c seekCursor, seeked bool) (*KeysetPage, error) {
+
+	more := len(rst.Data) > k.Limit
+	if more {
+		rst.Data = rst.Data[:k.Limit]
+		rst.Values = rst.Values[:k.Limit]
+		raw = raw[:k.Limit]
+	}
+
+	if c.Back {
+
+		for i, j := 0, len(rst.Data)-1; i < j; i, j = i+1, j-1 {
+			rst.Data[i], rst.Data[j] = rst.Data[j], rst.Data[i]
+			rst.Values[i], rst.Values[j] = rst.Values[j], rst.Values[i]
+			raw[i], raw[j] = raw[j], raw[i]
+		}
+	}
+
+	var (
+		err   error
+		first = c.Values
+		last  = c.Values
+		page  = &KeysetPage{Result: rst}
+	)
+
+	if len(raw) > 0 {
+
+		if first, err = k.keyValues(rst, raw[0]); err != nil {
+			return nil, err
+		}
+
+		if last, err = k.keyValues(rst, raw[len(raw)-1]); err != nil {
+			return nil, err
+		}
+	}
+
+	// Going forward there is a next page when the extra row was read,
+	// and a previous one when a cursor led here; backward the other way
+	// around.
+	hasNext, hasPrev := more, seeked
+	if c.Back {
+		hasNext, hasPrev = true, more
+	}
+
+	if hasNext && last != nil {
+		page.Next = k.encode(seekCursor{Values: last})
+	}
+
+	if hasPrev && first != nil {
+		page.Prev = k.encode(seekCursor{Values: first, Back: true})
+	}
+
+	return page, nil
+}
+
+func (k Keyset) keyValues(rst *Result, row []interface{}) ([]seekValue, error) {
+
+	var (
+		names  = rst.ColumnNames()
+		values = make([]seekValue, len(k.Keys))
+	)
+
+	for i, key := range k.Keys {
+
+		name := key.Column
+		if dot := strings.LastIndex(name, "."); dot >= 0 {
+			name = name[dot+1:]
+		}
+
+		x := -1
+		for j, n := range names {
+			if n == name {
+				x = j
+				break
+			}
+		}
+
+		if x < 0 {
+			return nil, fmt.Errorf("Column Not Found:%s", name)
+		}
+
+		values[i] = newSeekValue(row[x])
+	}
+
+	return values, nil
+}
+
+func newSeekValue(v interface{}) seekValue {
+
+	switch t := v.(type) {
+	case nil:
+		return seekValue{Type: "n"}
+	case int64:
+		return seekValue{Type: "i", Value: strconv.FormatInt(t, 10)}
+	case uint64:
+		return seekValue{Type: "u", Value: strconv.FormatUint(t, 10)}
+	case float64:
+		return seekValue{Type: "f", Value: strconv.FormatFloat(t, 'g', -1, 64)}
+	case bool:
+		return seekValue{Type: "b", Value: strconv.FormatBool(t)}
+	case []byte:
+		return seekValue{Type: "x", Value: base64.StdEncoding.EncodeToString(t)}
+	case time.Time:
+		return seekValue{Type: "t", Value: t.Format(time.RFC3339Nano)}
+	}
+
+	return seekValue{Type: "s", Value: fmt.Sprint(v)}
+}
+
+// value returns v as the type it was read with.
+func (v seekValue) value() (interface{}, error) {
+
+	switch v.Type {
+	case "n":
+		return nil, nil
+	case "i":
+		return strconv.ParseInt(v.Value, 10, 64)
+	case "u":
+		return strconv.ParseUint(v.Value, 10, 64)
+	case "f":
+		return strconv.ParseFloat(v.Value, 64)
+	case "b":
+		return strconv.ParseBool(v.Value)
+	case "x":
+		return base64.StdEncoding.DecodeString(v.Value)
+	case "t":
+		return time.Parse(time.RFC3339Nano, v.Value)
+	case "s":
+		return v.Value, nil
+	}
+
+	return nil, fmt.Errorf("Invalid Type:%s", v.Type)
+}
+
+// encode signs c together with the sort keys, so a cursor is only
+// accepted by the ordering that made it.
+func (k Keyset) encode(c seekCursor) string {
+
+	payload, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(k.sign(payload))
+}
+
+func (k Keyset) decode(cursor string) (seekCursor, error) {
+
+	var c seekCursor
+
+	parts := strings.Split(cursor, ".")
+	if len(parts) != 2 {
+		return c, ErrInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(mac, k.sign(payload)) {
+		return c, ErrInvalidCursor
+	}
+
+	if err = json.Unmarshal(payload, &c); err != nil || len(c.Values) != len(k.Keys) {
+		return c, ErrInvalidCursor
+	}
+
+	c.args = make([]interface{}, len(c.Values))
+	for i, v := range c.Values {
+
+		if c.args[i], err = v.value(); err != nil {
+			return c, ErrInvalidCursor
+		}
+	}
+
+	return c, nil
+}
+
+func (k Keyset) sign(payload []byte) []byte {
+
+	h := hmac.New(sha256.New, k.Secret)
+	for _, key := range k.Keys {
+		fmt.Fprintf(h, "%s %t\n", key.Column, key.Desc)
+	}
+	h.Write(payload)
+
+	return h.Sum(nil)
+}
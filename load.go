@@ -0,0 +1,422 @@
+// Copyright 2016 The Sqlcl Author. All Rights Reserved.
+//
+// -----------------------------------------------------
+
+package sqlcl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type LoadFormat int
+
+const (
+	// LoadCSV is RFC 4180: comma separated, fields optionally enclosed in
+	// double quotes, with "" for a quote inside them. It has no NULL.
+	// Lines end in \n, or \r\n throughout.
+	LoadCSV LoadFormat = iota
+
+	// LoadTSV is mysql's default text format: tab separated, with
+	// backslash escapes and \N for NULL.
+	LoadTSV
+)
+
+type LoadOptions struct {
+	Table   string
+	Columns []string // the column each field goes to, in order; "-" drops the field
+	Format  LoadFormat
+	Header  bool         // skip the first line
+	Batch   BatchOptions // chunking of the batch insert used off mysql
+}
+
+type LoadResult struct {
+	Rows     int64 // rows inserted
+	Bytes    int64 // bytes read from the reader
+	Duration time.Duration
+	Batch    *BatchResult // chunks of the batch insert, nil for LOAD DATA
+}
+
+var loadSeq uint64
+
+// Load streams r into a table. On mysql it runs LOAD DATA LOCAL INFILE,
+// which needs local_infile enabled on the server; elsewhere it reads the
+// rows and inserts them with BatchInsert in blocks, so r is never held in
+// memory whole.
+func (s *Server) Load(r io.Reader, o LoadOptions) (*LoadResult, error) {
+	return s.LoadContext(context.Background(), r, o)
+}
+
+func (s *Server) LoadContext(ctx context.Context, r io.Reader, o LoadOptions) (*LoadResult, error) {
+
+	if len(o.Columns) == 0 {
+		return nil, fmt.Errorf("No Columns")
+	}
+
+	for _, name := range append([]string{o.Table}, o.Columns...) {
+
+		if name == "-" {
+			continue
+		}
+
+		if err := checkIdent(s.dialect, name); err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		start = time.Now()
+		cr    = &countingReader{r: r}
+		rst   *LoadResult
+		err   error
+	)
+
+	if _, ok := s.db.Driver().(*mysql.MySQLDriver); ok {
+		rst, err = s.loadData(ctx, cr, o)
+	} else {
+		rst, err = s.loadBatch(ctx, cr, o)
+	}
+
+	if rst != nil {
+		rst.Bytes = cr.n
+		rst.Duration = time.Since(start)
+	}
+
+	return rst, err
+}
+
+func (s *Server) loadData(ctx context.Context, r io.Reader, o LoadOptions) (*LoadResult, error) {
+
+	var (
+		name = fmt.Sprintf("sqlcl_load_%d", atomic.AddUint64(&loadSeq, 1))
+		br   = bufio.NewReaderSize(r, 64<<10)
+	)
+
+	lines := loadLines(br, o.Format)
+
+	mysql.RegisterReaderHandler(name, func() io.Reader { return br })
+	defer mysql.DeregisterReaderHandler(name)
+
+	res, err := s.db.ExecContext(ctx, loadDataStatement(s.dialect, name, o, lines))
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoadResult{Rows: n}, nil
+}
+
+// loadLines returns the line terminator LOAD DATA is to split r with, as
+// an SQL literal. CSV lines ending in \r\n, judged by the first line in
+// r's buffer, are split there, as encoding/csv does off mysql; mysql's
+// own text format always ends its lines with \n.
+func loadLines(r *bufio.Reader, f LoadFormat) string {
+
+	if f == LoadCSV {
+
+		b, _ := r.Peek(r.Size())
+		if i := bytes.IndexByte(b, '\n'); i > 0 && b[i-1] == '\r' {
+			return `'\r\n'`
+		}
+	}
+
+	return `'\n'`
+}
+
+func loadDataStatement(d Dialect, name string, o LoadOptions, lines string) string {
+
+	var (
+		b    strings.Builder
+		cols = make([]string, len(o.Columns))
+	)
+
+	for i, col := range o.Columns {
+
+		cols[i] = "@sqlcl_skip"
+		if col != "-" {
+			cols[i] = d.QuoteIdent(col)
+		}
+	}
+
+	fmt.Fprintf(&b, "LOAD DATA LOCAL INFILE 'Reader::%s' INTO TABLE %s CHARACTER SET utf8mb4", name, d.QuoteIdent(o.Table))
+
+	switch o.Format {
+	case LoadTSV:
+		b.WriteString(` FIELDS TERMINATED BY '\t' ENCLOSED BY '' ESCAPED BY '\\'`)
+	default:
+		b.WriteString(` FIELDS TERMINATED BY ',' OPTIONALLY ENCLOSED BY '"' ESCAPED BY ''`)
+	}
+
+	b.WriteString(" LINES TERMINATED BY " + lines)
+
+	if o.Header {
+		b.WriteString(" IGNORE 1 LINES")
+	}
+
+	fmt.Fprintf(&b, " (%s)", strings.Join(cols, ","))
+
+	return b.String()
+}
+
+// loadBlock is how many rows loadBatch reads before inserting them.
+const loadBlock = 10000
+
+func (s *Server) loadBatch(ctx context.Context, r io.Reader, o LoadOptions) (*LoadResult, error) {
+
+	var (
+		columns []string
+		keep    []int
+	)
+
+	for i, col := range o.Columns {
+
+		if col != "-" {
+			columns = append(columns, col)
+			keep = append(keep, i)
+		}
+	}
+
+	bo := o.Batch.withDefaults(s.dialect)
+	rst := &LoadResult{Batch: &BatchResult{}}
+
+	run := func(db execer) error {
+
+		var (
+			chunkErr error
+			rr       = newLoadReader(r, o.Format)
+			rows     [][]interface{}
+			first    = 0
+			line     = 0
+		)
+
+		// flush inserts the rows read so far. Failed chunks only stop the
+		// load in a transaction; anything else, like a cancelled ctx,
+		// always does.
+		flush := func() error {
+
+			err := batchInsert(ctx, db, s.dialect, bo, o.Table, columns, rows, first, rst.Batch)
+			first += len(rows)
+			rows = rows[:0]
+
+			var cerr *ChunkError
+			if err != nil && (bo.Tx || !errors.As(err, &cerr)) {
+				return err
+			}
+
+			if chunkErr == nil {
+				chunkErr = err
+			}
+			return nil
+		}
+
+		for {
+
+			fields, rerr := rr.read()
+			if rerr == io.EOF {
+				break
+			}
+			if rerr != nil {
+				return rerr
+			}
+
+			line++
+			if line == 1 && o.Header {
+				continue
+			}
+
+			if len(fields) != len(o.Columns) {
+				return fmt.Errorf("Invalid Line %d: %d fields for %d columns", line, len(fields), len(o.Columns))
+			}
+
+			row := make([]interface{}, len(keep))
+			for i, x := range keep {
+				row[i] = fields[x]
+			}
+
+			rows = append(rows, row)
+			if len(rows) >= loadBlock {
+
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(rows) > 0 {
+
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+
+		return chunkErr
+	}
+
+	var err error
+	if bo.Tx {
+
+		err = s.WithTxOptions(ctx, bo.TxOptions, func(tx *Tx) error {
+			return run(tx.tx)
+		})
+		if err != nil {
+			rst.Batch.RowsAffected = 0
+		}
+	} else {
+		err = run(s.db)
+	}
+
+	rst.Rows = rst.Batch.RowsAffected
+
+	return rst, err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+
+	return n, err
+}
+
+// loadReader splits the input into records as LOAD DATA does, with NULL
+// fields as nil.
+type loadReader struct {
+	csv *csv.Reader
+	tsv *bufio.Reader
+}
+
+func newLoadReader(r io.Reader, f LoadFormat) *loadReader {
+
+	if f == LoadTSV {
+		return &loadReader{tsv: bufio.NewReader(r)}
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	return &loadReader{csv: cr}
+}
+
+func (l *loadReader) read() ([]interface{}, error) {
+
+	if l.csv != nil {
+
+		record, err := l.csv.Read()
+		if err != nil {
+			return nil, err
+		}
+
+		fields := make([]interface{}, len(record))
+		for i, v := range record {
+			fields[i] = v
+		}
+
+		return fields, nil
+	}
+
+	return l.readTSV()
+}
+
+// readTSV reads one line of mysql's text format. A backslash escapes the
+// next byte, which keeps an escaped tab or newline inside the field, and
+// a field of just \N is NULL.
+func (l *loadReader) readTSV() ([]interface{}, error) {
+
+	var (
+		fields  []interface{}
+		field   strings.Builder
+		escaped = false
+		null    = false
+		started = false
+	)
+
+	end := func() {
+
+		if null {
+			fields = append(fields, nil)
+		} else {
+			fields = append(fields, field.String())
+		}
+
+		field.Reset()
+		null = false
+	}
+
+	for {
+
+		c, err := l.tsv.ReadByte()
+		if err == io.EOF {
+
+			if !started {
+				return nil, io.EOF
+			}
+
+			end()
+			return fields, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		started = true
+
+		if escaped {
+
+			escaped = false
+			if c == 'N' && field.Len() == 0 {
+				null = true
+				continue
+			}
+
+			if r, ok := tsvEscapes[c]; ok {
+				c = r
+			}
+
+			null = false
+			field.WriteByte(c)
+			continue
+		}
+
+		switch c {
+		case '\\':
+			escaped = true
+		case '\t':
+			end()
+		case '\n':
+			end()
+			return fields, nil
+		default:
+			null = false
+			field.WriteByte(c)
+		}
+	}
+}
+
+var tsvEscapes = map[byte]byte{
+	'0': 0,
+	'b': '\b',
+	'n': '\n',
+	'r': '\r',
+	't': '\t',
+	'Z': 0x1a,
+}
@@ -5,46 +5,323 @@
 package sqlcl
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 func TestMysqlSql(t *testing.T) {
 
 	var (
 		qset  = NewQuerySet()
-		qneed = strings.TrimSpace("SELECT *  FROM `test_temp`  WHERE id   = \"30000\"   AND id   > \"40000\"   OR title   != \"title_01\"  LIMIT 100,20")
+		qneed = strings.TrimSpace("SELECT *  FROM `test_temp`  WHERE `id`   = ?   AND `id`   > ?   OR `title`   != ?  LIMIT 100,20")
 	)
 
 	// ==========================================================
 	qset.Clear().Select("*").From("test_temp").Where("id").Eq("30000").And("id").Gt("40000").Or("title").Neq("title_01").Limit(100, 20)
 
 	do_sql_test(qneed, qset, t)
+	do_args_test([]interface{}{"30000", "40000", "title_01"}, qset, t)
 
 	// ==========================================================
-	qneed = strings.TrimSpace("INSERT INTO  `test_temp`  (title,content)  VALUES ('fdsfds','fdsfd'),('vvvvvv','ddddd')")
+	qneed = strings.TrimSpace("INSERT INTO  `test_temp`  (`title`,`content`)  VALUES ('fdsfds','fdsfd'),('vvvvvv','ddddd')")
 
 	qset.Clear().InsertTable("test_temp").InsertFields("title,content").InsertValues("('fdsfds','fdsfd'),('vvvvvv','ddddd')")
 	do_sql_test(qneed, qset, t)
 
 	// ==========================================================
-	qneed = strings.TrimSpace("UPDATE  `test_temp`  SET title='fffff',content='ccccccccccccccccccc'  WHERE id   = \"30000\"   OR id   > \"100000\"")
+	qneed = strings.TrimSpace("UPDATE  `test_temp`  SET title='fffff',content='ccccccccccccccccccc'  WHERE `id`   = ?   OR `id`   > ?")
 
 	qset.Clear().UpdateTable("test_temp").UpdateSet("title='fffff',content='ccccccccccccccccccc'").Where("id").Eq("30000").Or("id").Gt("100000")
 	do_sql_test(qneed, qset, t)
+	do_args_test([]interface{}{"30000", "100000"}, qset, t)
+
+	// ==========================================================
+	qneed = strings.TrimSpace("SELECT *  FROM `test_temp`  WHERE `id`   IN (?,?,?,?)")
+	qset.Clear().Select("*").From("test_temp").Where("id").In(31, 32, 33, 100)
+	do_sql_test(qneed, qset, t)
+	do_args_test([]interface{}{31, 32, 33, 100}, qset, t)
+
+	// ==========================================================
+	qneed = strings.TrimSpace("DELETE  FROM `test_temp`  WHERE `id`   IN (?,?,?,?,?)")
+	qset.Clear().Delete().From("test_temp").Where("id").In(31, 32, 33, 500, 1000)
+	do_sql_test(qneed, qset, t)
+	do_args_test([]interface{}{31, 32, 33, 500, 1000}, qset, t)
+
+	// ==========================================================
+	qneed = strings.TrimSpace("UPDATE  `test_temp`  SET title=?  WHERE `id`   = ?   AND `title`   LIKE ?")
+	qset.Clear().UpdateTable("test_temp").UpdateSet("title=?").Where("id").Eq(Param).And("title").Like("\" OR 1=1 -- ")
+	do_sql_test(qneed, qset, t)
+
+	if _, args, _ := qset.Build("new_title", 7); !reflect.DeepEqual(args, []interface{}{"new_title", 7, "\" OR 1=1 -- "}) {
+		t.Errorf("Args not matched. args:%v", args)
+	}
+
+}
+
+func TestMysqlJoinSql(t *testing.T) {
+
+	var (
+		sub   = NewQuerySet().Select("user_id, COUNT(*) AS num").From("orders").Where("status").Eq(1).GroupBy("user_id")
+		qset  = NewQuerySet()
+		qneed = strings.TrimSpace("SELECT `u`.`id`, `o`.`num`  FROM `users`  LEFT JOIN `profiles` AS `p` ON p.user_id = u.id  " +
+			"INNER JOIN `teams` AS `t` ON t.id = u.team_id  RIGHT JOIN (SELECT `user_id`, COUNT(*) AS num  FROM `orders`  " +
+			"WHERE `status`   = ?  GROUP BY `user_id`) AS `o` ON `o`.`user_id` = ?  LEFT JOIN `extra` USING (`id`,`kind`)  " +
+			"CROSS JOIN `dual`  WHERE `u`.`id`   > ?")
+	)
+
+	// Joins keep the order they were added in, LEFT before INNER here.
+	qset.Select("u.id, o.num").From("users").
+		LeftJoinAsOn("profiles", "p", "p.user_id = u.id").
+		InnerJoinAsOn("teams", "t", "t.id = u.team_id").
+		Join(JoinRight, sub, "o", Eq("o.user_id", 9)).
+		JoinUsing(JoinLeft, "extra", "", "id", "kind").
+		CrossJoin("dual", "").
+		Where("u.id").Gt(5)
+
+	do_sql_test(qneed, qset, t)
+	do_args_test([]interface{}{1, 9, 5}, qset, t)
+
+	for i, on := range []interface{}{nil, 7, " "} {
+		if _, _, err := NewQuerySet().Select("*").From("users").Join(JoinInner, "teams", "t", on).Build(); err == nil {
+			t.Fatalf("Join.#%03d on:%v expected invalid join condition error\n", i+1, on)
+		}
+	}
+
+	// mysql has no FULL OUTER JOIN.
+	for i, d := range []Dialect{DialectMySQL, DialectMySQL8} {
+		if _, _, err := NewQuerySet().UseDialect(d).Select("*").From("users").JoinUsing(JoinFull, "extra", "", "id").Build(); !errors.Is(err, ErrUnsupported) {
+			t.Fatalf("Join.#%03d err:%v\n", i+101, err)
+		}
+	}
+
+	qneed = strings.TrimSpace(`SELECT *  FROM "users"  FULL OUTER JOIN "extra" USING ("id")`)
+	qset.Clear().UseDialect(DialectPostgres).Select("*").From("users").JoinUsing(JoinFull, "extra", "", "id")
+	do_sql_test(qneed, qset, t)
+}
+
+func TestMysqlSubquerySql(t *testing.T) {
+
+	var (
+		paid  = NewQuerySet().Select("user_id").From("orders").Where("status").Eq(1)
+		last  = NewQuerySet().Select("MAX(created)").From("orders").Where(Expr("orders.user_id = u.id")).And("kind").Eq("web")
+		qset  = NewQuerySet()
+		qneed = strings.TrimSpace("SELECT `u`.`id`, (SELECT MAX(created)  FROM `orders`  WHERE orders.user_id = u.id   AND `kind`   = ?) AS `last`  " +
+			"FROM (SELECT `id`, `team_id`  FROM `users`  WHERE `age`   > ?)  AS `u`  WHERE `u`.`id`   IN (SELECT `user_id`  FROM `orders`  WHERE `status`   = ?)   " +
+			"AND EXISTS (SELECT `id`  FROM `teams`  WHERE teams.id = u.team_id)   AND `u`.`team_id`   != (SELECT `id`  FROM `teams`  WHERE `name`   = ?)")
+	)
+
+	qset.Select("u.id").SelectSub(last, "last").
+		FromAs(NewQuerySet().Select("id, team_id").From("users").Where("age").Gt(18), "u").
+		Where("u.id").In(paid).
+		And(Exists(NewQuerySet().Select("id").From("teams").Where(Expr("teams.id = u.team_id")))).
+		And("u.team_id").Neq(NewQuerySet().Select("id").From("teams").Where("name").Eq(Param))
+
+	do_sql_test(qneed, qset, t)
+
+	// Args follow the text: select list, FROM, then the filters; the open
+	// "?" of the last subquery takes the call argument.
+	if _, args, _ := qset.Build("core"); !reflect.DeepEqual(args, []interface{}{"web", 18, 1, "core"}) {
+		t.Fatalf("Subquery.#001 args:%v\n", args)
+	}
+}
+
+func TestMysqlCteSql(t *testing.T) {
+
+	var (
+		paid  = NewQuerySet().Select("user_id").From("orders").Where("status").Eq(1)
+		qset  = NewQuerySet()
+		qneed = strings.TrimSpace("WITH `paid` AS (SELECT `user_id`  FROM `orders`  WHERE `status`   = ?), " +
+			"`big` AS (SELECT `user_id`  FROM `paid`)  SELECT `id`  FROM `users`  WHERE `id`   IN (SELECT `user_id`  FROM `big`)")
+	)
+
+	qset.With("paid", paid).With("big", NewQuerySet().Select("user_id").From("paid")).
+		Select("id").From("users").Where("id").In(NewQuerySet().Select("user_id").From("big"))
+
+	do_sql_test(qneed, qset, t)
+	do_args_test([]interface{}{1}, qset, t)
+
+	qset.Clear()
+	qneed = strings.TrimSpace("WITH RECURSIVE `tree`(`id`) AS (SELECT `id`  FROM `categories`  WHERE `parent_id`   = ?)  " +
+		"DELETE  FROM `categories`  WHERE `id`   IN (SELECT `id`  FROM `tree`)")
+
+	qset.WithRecursive("tree(id)", NewQuerySet().Select("id").From("categories").Where("parent_id").Eq(3)).
+		Delete().From("categories").Where("id").In(NewQuerySet().Select("id").From("tree"))
+
+	do_sql_test(qneed, qset, t)
+	do_args_test([]interface{}{3}, qset, t)
+}
+
+func TestMysqlSetOpSql(t *testing.T) {
+
+	var (
+		qset  = NewQuerySet()
+		qneed = strings.TrimSpace("SELECT `id`, `name`  FROM `users`  WHERE `age`   > ?  UNION ALL SELECT `id`, `name`  FROM `admins`  " +
+			"UNION SELECT `id`, `name`  FROM `guests`  WHERE `kind`   = ?  ORDER BY `name` LIMIT 0,10")
+	)
+
+	qset.Select("id, name").From("users").Where("age").Gt(18).
+		UnionAll(NewQuerySet().Select("id, name").From("admins")).
+		Union(NewQuerySet().Select("id, name").From("guests").Where("kind").Eq(Param)).
+		OrderBy("name").Limit(0, 10)
+
+	do_sql_test(qneed, qset, t)
+
+	if _, args, _ := qset.Build("vip"); !reflect.DeepEqual(args, []interface{}{18, "vip"}) {
+		t.Fatalf("SetOp.#001 args:%v\n", args)
+	}
+
+	qset.Clear()
+	qset.Select("id").From("users").Intersect(NewQuerySet().Select("user_id").From("orders"))
+
+	if _, _, err := qset.Build(); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("SetOp.#002 err:%v\n", err)
+	}
+
+	// A subquery is checked with the statement it is nested in.
+	outer := NewQuerySet().Select("name").From("users").Where("id").In(qset)
+	if _, _, err := outer.Build(); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("SetOp.#003 err:%v\n", err)
+	}
+
+	qneed = "SELECT `id`  FROM `users`  INTERSECT SELECT `user_id`  FROM `orders`"
+	if sql, _, err := qset.UseDialect(DialectMySQL8).Build(); err != nil || strings.TrimSpace(sql) != qneed {
+		t.Fatalf("SetOp.#004 sql:%s err:%v\n", sql, err)
+	}
+}
+
+func TestMysqlColumnsSql(t *testing.T) {
+
+	var (
+		qset  = NewQuerySet()
+		qneed = "INSERT INTO  `foo`  (`age`,`title`)  VALUES (?,?)"
+	)
+
+	qset.InsertTable("foo").InsertRow(map[string]interface{}{"title": "it's", "age": 3, "id": 0}, Omit("id"))
+	do_sql_test(qneed, qset, t)
+	do_args_test([]interface{}{3, "it's"}, qset, t)
 
 	// ==========================================================
-	qneed = strings.TrimSpace("SELECT *  FROM `test_temp`  WHERE id   IN (31,32,33,100)")
-	qset.Clear().Select("*").From("test_temp").Where("id").In("31,32,33,100")
+	qneed = "INSERT INTO  `foo`  (`age`,`title`,`note`)  VALUES (?,?,?),(?,?,?)"
+	qset.Clear().InsertTable("foo").InsertRows([]map[string]interface{}{
+		{"title": "a", "age": 1, "flag": false},
+		{"title": "b", "age": 0, "note": "x", "flag": false},
+	}, SkipZero())
 	do_sql_test(qneed, qset, t)
+	do_args_test([]interface{}{1, "a", nil, 0, "b", "x"}, qset, t)
 
 	// ==========================================================
-	qneed = strings.TrimSpace("DELETE  FROM `test_temp`  WHERE id   IN (31,32,33,500,1000)")
-	qset.Clear().Delete().From("test_temp").Where("id").In("31,32,33,500,1000")
+	qneed = "UPDATE  `foo`  SET `ctime`=(SELECT NOW()),`title`=?  WHERE `id`   = ?"
+	qset.Clear().UpdateTable("foo").UpdateMap(map[string]interface{}{
+		"title": "?",
+		"ctime": NewQuerySet().Select("NOW()"),
+		"note":  "",
+	}, SkipZero()).Where("id").Eq(7)
 	do_sql_test(qneed, qset, t)
 
+	if _, args, _ := qset.Build(); !reflect.DeepEqual(args, []interface{}{"?", 7}) {
+		t.Fatalf("Columns.#001 args:%v\n", args)
+	}
+
+	if _, _, err := qset.Clear().InsertTable("foo").InsertStruct(7).Build(); err == nil {
+		t.Fatalf("Columns.#002 err:%v\n", err)
+	}
+}
+
+func TestMysqlLoadDataSql(t *testing.T) {
+
+	qneed := "LOAD DATA LOCAL INFILE 'Reader::sqlcl_load_1' INTO TABLE `foo` CHARACTER SET utf8mb4 " +
+		"FIELDS TERMINATED BY ',' OPTIONALLY ENCLOSED BY '\"' ESCAPED BY '' LINES TERMINATED BY '\\n' IGNORE 1 LINES (`id`,@sqlcl_skip,`title`)"
+
+	if sql := loadDataStatement(DialectMySQL, "sqlcl_load_1", LoadOptions{Table: "foo", Columns: []string{"id", "-", "title"}, Header: true}, `'\n'`); sql != qneed {
+		t.Fatalf("LoadData.#001 sql:%s\n", sql)
+	}
+
+	qneed = "LOAD DATA LOCAL INFILE 'Reader::sqlcl_load_2' INTO TABLE `foo` CHARACTER SET utf8mb4 " +
+		"FIELDS TERMINATED BY '\\t' ENCLOSED BY '' ESCAPED BY '\\\\' LINES TERMINATED BY '\\n' (`id`)"
+
+	if sql := loadDataStatement(DialectMySQL, "sqlcl_load_2", LoadOptions{Table: "foo", Columns: []string{"id"}, Format: LoadTSV}, `'\n'`); sql != qneed {
+		t.Fatalf("LoadData.#002 sql:%s\n", sql)
+	}
+
+	// CRLF lines are split where encoding/csv splits them off mysql, and
+	// the peek leaves the data whole.
+	for i, c := range []struct {
+		data   string
+		format LoadFormat
+		need   string
+	}{
+		{"id,title\r\n1,a\r\n", LoadCSV, `'\r\n'`},
+		{"id,title\n1,a\r\n", LoadCSV, `'\n'`},
+		{"1\ta\r\n", LoadTSV, `'\n'`},
+		{"", LoadCSV, `'\n'`},
+	} {
+
+		br := bufio.NewReader(strings.NewReader(c.data))
+		if lines := loadLines(br, c.format); lines != c.need {
+			t.Fatalf("LoadData.#%03d lines:%s\n", i+3, lines)
+		}
+
+		if rest, _ := io.ReadAll(br); string(rest) != c.data {
+			t.Fatalf("LoadData.#%03d rest:%q\n", i+3, rest)
+		}
+	}
+
+	qneed = "LOAD DATA LOCAL INFILE 'Reader::sqlcl_load_3' INTO TABLE `foo` CHARACTER SET utf8mb4 " +
+		"FIELDS TERMINATED BY ',' OPTIONALLY ENCLOSED BY '\"' ESCAPED BY '' LINES TERMINATED BY '\\r\\n' IGNORE 1 LINES (`id`,`title`)"
+
+	br := bufio.NewReader(strings.NewReader("id,title\r\n1,a\r\n"))
+	if sql := loadDataStatement(DialectMySQL, "sqlcl_load_3", LoadOptions{Table: "foo", Columns: []string{"id", "title"}, Header: true}, loadLines(br, LoadCSV)); sql != qneed {
+		t.Fatalf("LoadData.#007 sql:%s\n", sql)
+	}
+}
+
+func TestMysqlSeekSql(t *testing.T) {
+
+	var (
+		k    = Keyset{Keys: []SortKey{{Column: "a"}, {Column: "b", Desc: true}}, Limit: 10, Secret: []byte("secret")}
+		qset = NewQuerySet().Select("*").From("foo").Where("c").Eq(1).Or(Eq("d", 2))
+	)
+
+	q, _, err := k.query(qset, "")
+	if err != nil {
+		t.Fatalf("SeekSql.#001 err:%v\n", err)
+	}
+
+	do_sql_test("SELECT *  FROM `foo`  WHERE `c`   = ?   OR `d` = ?  ORDER BY `a`, `b` DESC LIMIT 0,11", q, t)
+
+	q, _, err = k.query(qset, k.encode(seekCursor{Values: []seekValue{{Type: "s", Value: "x"}, {Type: "i", Value: "2"}}, Back: true}))
+	if err != nil {
+		t.Fatalf("SeekSql.#002 err:%v\n", err)
+	}
+
+	do_sql_test("SELECT *  FROM `foo`  WHERE (`c`   = ?   OR `d` = ?) AND (`a` < ? OR (`a` = ? AND `b` > ?))  ORDER BY `a` DESC, `b` LIMIT 0,11", q, t)
+	do_args_test([]interface{}{1, 2, "x", "x", int64(2)}, q, t)
+}
+
+func TestMysqlCountSql(t *testing.T) {
+
+	q, err := NewQuerySet().Select("id, title").From("foo").Where("id").Gt(1).OrderBy("id").Limit(0, 10).countQuery(nil)
+	if err != nil {
+		t.Fatalf("CountSql.#001 err:%v\n", err)
+	}
+
+	do_sql_test("SELECT COUNT(*) AS sqlcl_count  FROM `foo`  WHERE `id`   > ?", q, t)
+	do_args_test([]interface{}{1}, q, t)
+
+	q, err = NewQuerySet().Select("title, COUNT(*)").From("foo").GroupBy("title").OrderBy("title").countQuery(nil)
+	if err != nil {
+		t.Fatalf("CountSql.#002 err:%v\n", err)
+	}
+
+	do_sql_test("SELECT COUNT(*) AS sqlcl_count  FROM (SELECT `title`, COUNT(*)  FROM `foo`  GROUP BY `title`)  AS `sqlcl_rows`", q, t)
 }
 
 func TestMysqlDB(t *testing.T) {
@@ -85,7 +362,7 @@ func TestMysqlDB(t *testing.T) {
 
 	// t.Logf("sql:%s\n", qset.sql())
 
-	qset.Clear().Select("*").From("test_temp").Where("id").In("?,?,?")
+	qset.Clear().Select("*").From("test_temp").Where("id").In(Param, Param, Param)
 
 	// t.Logf("sql:%s\n", qset.sql())
 
@@ -205,7 +482,7 @@ func TestMysqlRollBack(t *testing.T) {
 		// t.Logf("New.db.Tx lid:%d aft:%d\n", lid, aft)
 	}
 
-	if err := db.TxPrepare(qset.Clear().UpdateTable("test_temp").UpdateSet("title=?,content=?").Where("id").Eq("?")); err != nil {
+	if err := db.TxPrepare(qset.Clear().UpdateTable("test_temp").UpdateSet("title=?,content=?").Where("id").Eq(Param)); err != nil {
 		t.Fatalf("db.TxPrepare err:%s", err.Error())
 	}
 
@@ -257,6 +534,43 @@ func TestMysqlRollBack(t *testing.T) {
 	db.ExecString(`drop table test_temp`)
 }
 
+func TestMysqlRetryable(t *testing.T) {
+
+	for num, need := range map[uint16]bool{1213: true, 1205: true, 1062: false} {
+
+		if IsRetryable(fmt.Errorf("wrapped: %w", &mysql.MySQLError{Number: num})) != need {
+			t.Errorf("IsRetryable(%d) != %v", num, need)
+		}
+	}
+
+	if IsRetryable(fmt.Errorf("Not Found")) {
+		t.Errorf("IsRetryable(Not Found) != false")
+	}
+}
+
+func TestMysqlClassify(t *testing.T) {
+
+	for num, need := range map[uint16]ErrorKind{
+		1062: KindDuplicateKey,
+		1452: KindForeignKey,
+		1048: KindNotNull,
+		1213: KindDeadlock,
+		1205: KindLockTimeout,
+		2013: KindConnectionLost,
+		1064: KindSyntax,
+		1146: KindUnknown,
+	} {
+
+		if kind := Classify(fmt.Errorf("wrapped: %w", &mysql.MySQLError{Number: num})); kind != need {
+			t.Errorf("Classify(%d) = %s, need %s", num, kind, need)
+		}
+	}
+
+	if kind := Classify(mysql.ErrInvalidConn); kind != KindConnectionLost {
+		t.Errorf("Classify(ErrInvalidConn) = %s", kind)
+	}
+}
+
 func do_sql_test(qneed string, q *QuerySet, t *testing.T) {
 
 	//	pass := true
@@ -274,6 +588,13 @@ func do_sql_test(qneed string, q *QuerySet, t *testing.T) {
 	//	t.Logf("pass:%v sql:%s\n", pass, qneed)
 }
 
+func do_args_test(aneed []interface{}, q *QuerySet, t *testing.T) {
+
+	if _, args, _ := q.Build(); !reflect.DeepEqual(args, aneed) {
+		t.Errorf("Args not matched. args:%v", args)
+	}
+}
+
 func tx_rollback() bool {
 
 	for i := 1; i < len(os.Args); i++ {
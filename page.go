@@ -0,0 +1,144 @@
+// Copyright 2016 The Sqlcl Author. All Rights Reserved.
+//
+// -----------------------------------------------------
+
+package sqlcl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Page is one page of an offset paginated query, numbered from 1.
+type Page struct {
+	*Result
+	Number  int
+	PerPage int
+	Total   int64 // rows of the whole query
+	Pages   int64
+	HasNext bool
+	HasPrev bool
+}
+
+// Paginate runs page of q, perPage rows long, and the count of all rows
+// q selects. q must not set LIMIT, which Paginate adds; args are used by
+// both statements. A page past the end comes back empty.
+func (s *Server) Paginate(q *QuerySet, page, perPage int, args ...interface{}) (*Page, error) {
+	return s.PaginateContext(context.Background(), q, page, perPage, args...)
+}
+
+func (s *Server) PaginateContext(ctx context.Context, q *QuerySet, page, perPage int, args ...interface{}) (*Page, error) {
+	return paginate(s.dialect, q, page, perPage, func(cq *QuerySet) (*RowColumn, error) {
+		return s.QueryRowContext(ctx, cq, args...)
+	}, func(lq *QuerySet) (*Result, error) {
+		return s.QueryContext(ctx, lq, args...)
+	})
+}
+
+func (t *Tx) Paginate(q *QuerySet, page, perPage int, args ...interface{}) (*Page, error) {
+	return t.PaginateContext(context.Background(), q, page, perPage, args...)
+}
+
+func (t *Tx) PaginateContext(ctx context.Context, q *QuerySet, page, perPage int, args ...interface{}) (*Page, error) {
+	return paginate(t.dialect, q, page, perPage, func(cq *QuerySet) (*RowColumn, error) {
+		return t.QueryRowContext(ctx, cq, args...)
+	}, func(lq *QuerySet) (*Result, error) {
+		return t.QueryContext(ctx, lq, args...)
+	})
+}
+
+// paginate counts the rows of q with count, then reads the page with
+// query from a copy of q limited to it, unless the page is past the end.
+func paginate(d Dialect, q *QuerySet, page, perPage int, count func(*QuerySet) (*RowColumn, error), query func(*QuerySet) (*Result, error)) (*Page, error) {
+
+	if page < 1 || perPage < 1 {
+		return nil, fmt.Errorf("Invalid Page:%d PerPage:%d", page, perPage)
+	}
+
+	cq, err := q.countQuery(d)
+	if err != nil {
+		return nil, err
+	}
+
+	row, err := count(cq)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		total  = row.Int64("sqlcl_count")
+		offset = int64(page-1) * int64(perPage)
+		p      = &Page{
+			Result:  &Result{},
+			Number:  page,
+			PerPage: perPage,
+			Total:   total,
+			Pages:   (total + int64(perPage) - 1) / int64(perPage),
+		}
+	)
+
+	p.HasNext = int64(page) < p.Pages
+	p.HasPrev = page > 1 && p.Pages > 0
+
+	if offset >= total {
+		return p, nil
+	}
+
+	rst, err := query(q.clone().Limit(uint64(offset), uint64(perPage)))
+	if err != nil {
+		return nil, err
+	}
+
+	p.Result = rst
+
+	return p, nil
+}
+
+// countQuery derives the COUNT(*) of the rows q selects, without its
+// ORDER BY and LIMIT. When DISTINCT, GROUP BY, HAVING or a compound
+// decide the rows, q is counted as a subquery instead, as it is when its
+// select list or ORDER BY hold arguments, so the arguments still line up.
+func (q *QuerySet) countQuery(d Dialect) (*QuerySet, error) {
+
+	if q.dialect != nil {
+		d = q.dialect
+	}
+
+	if d == nil {
+		d = DialectMySQL
+	}
+
+	c := q.clone()
+	delete(c.set, QLIMIT)
+
+	sel, sargs, err := c.set[QSELECT].build(d)
+	if err != nil {
+		return nil, err
+	}
+
+	_, oargs, err := c.set[QORDERBY].build(d)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(oargs) == 0 {
+		delete(c.set, QORDERBY)
+	}
+
+	var (
+		_, group  = c.set[QGROUPBY]
+		_, having = c.set[QHAVING]
+		fields    = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(sel), QSELECT[1:]))
+		distinct  = strings.HasPrefix(strings.ToUpper(fields), "DISTINCT")
+	)
+
+	if !group && !having && !distinct && len(c.compounds) == 0 && len(sargs) == 0 && len(oargs) == 0 {
+		return c.Select("COUNT(*) AS sqlcl_count"), nil
+	}
+
+	outer := NewQuerySet().Select("COUNT(*) AS sqlcl_count").FromAs(c, "sqlcl_rows")
+	outer.dialect = q.dialect
+
+	return outer, nil
+}
@@ -33,26 +33,86 @@ const (
 	QHAVING       = "7HAVING"
 	QORDERBY      = "8ORDER BY"
 	QLIMIT        = "9LIMIT"
+	QUPSERT       = ":UPSERT" // sorts after LIMIT
 )
 
+type JoinKind string
+
+const (
+	JoinInner JoinKind = "INNER JOIN"
+	JoinLeft  JoinKind = "LEFT JOIN"
+	JoinRight JoinKind = "RIGHT JOIN"
+	JoinFull  JoinKind = "FULL OUTER JOIN"
+	JoinCross JoinKind = "CROSS JOIN"
+)
+
+type SetOp string
+
+const (
+	SetUnion     SetOp = "UNION"
+	SetUnionAll  SetOp = "UNION ALL"
+	SetIntersect SetOp = "INTERSECT"
+	SetExcept    SetOp = "EXCEPT"
+)
+
+// qplaceholder marks a bound position whose value is supplied when the
+// query is executed instead of when it is built.
+type qplaceholder struct{}
+
+// Param leaves a value open to be passed when the query is executed, as
+// in Where("id").Eq(sqlcl.Param) followed by db.Query(q, id). Every other
+// value, the string "?" included, is bound as it is.
+var Param = qplaceholder{}
+
+// qpart is one rendered piece of a statement. Pieces that depend on the
+// dialect or on another QuerySet, such as quoted names and subqueries, set
+// render instead of sql and args.
+type qpart struct {
+	sql    string
+	args   []interface{}
+	render func(d Dialect) (string, []interface{}, error)
+}
+
 type QuerySet struct {
-	stmt    *sql.Stmt
-	tx      *sql.Tx
-	filters []string
-	set     map[string]string
+	stmt      *sql.Stmt
+	tx        *sql.Tx
+	dialect   Dialect
+	ctes      []qpart
+	recursive bool
+	compounds []qpart
+	joins     []qpart
+	filters   []qpart
+	column    filterColumn
+	set       map[string]qpart
+}
+
+// filterColumn is the filter a Where, And or Or taking a column name
+// added, for an empty In or NotIn to replace with a constant.
+type filterColumn struct {
+	keyword string
+	index   int
 }
 
 func NewQuerySet() *QuerySet {
 	return &QuerySet{
-		filters: []string{},
-		set:     make(map[string]string),
+		ctes:      []qpart{},
+		compounds: []qpart{},
+		joins:     []qpart{},
+		filters:   []qpart{},
+		column:    filterColumn{index: -1},
+		set:       make(map[string]qpart),
 	}
 }
 
 func (q *QuerySet) Clear() *QuerySet {
 
-	q.set = make(map[string]string)
-	q.filters = []string{}
+	q.set = make(map[string]qpart)
+	q.ctes = []qpart{}
+	q.recursive = false
+	q.compounds = []qpart{}
+	q.joins = []qpart{}
+	q.filters = []qpart{}
+	q.column = filterColumn{index: -1}
 
 	if q.stmt != nil {
 		q.stmt.Close()
@@ -62,284 +122,593 @@ func (q *QuerySet) Clear() *QuerySet {
 	return q
 }
 
+// clone copies the clauses of q, so the copy can be changed without
+// touching q. A prepared statement is not shared.
+func (q *QuerySet) clone() *QuerySet {
+
+	c := &QuerySet{
+		tx:        q.tx,
+		dialect:   q.dialect,
+		ctes:      append([]qpart{}, q.ctes...),
+		recursive: q.recursive,
+		compounds: append([]qpart{}, q.compounds...),
+		joins:     append([]qpart{}, q.joins...),
+		filters:   append([]qpart{}, q.filters...),
+		column:    q.column,
+		set:       make(map[string]qpart, len(q.set)),
+	}
+
+	for k, v := range q.set {
+		c.set[k] = v
+	}
+
+	return c
+}
+
+// UseDialect pins the dialect q renders with. Without it q renders for
+// the Server that runs it, or for mysql when built directly.
+func (q *QuerySet) UseDialect(d Dialect) *QuerySet {
+	q.dialect = d
+	return q
+}
+
+// With names sub as a common table expression of the select, update or
+// delete statement q builds. name may carry a column list, as in
+// "tree(id, depth)". Expressions render in the order they were added.
+func (q *QuerySet) With(name string, sub *QuerySet) *QuerySet {
+
+	head := identPart("%s", name)
+	if i := strings.Index(name, "("); i > 0 && strings.HasSuffix(name, ")") {
+		head = concatPart(identPart("%s", strings.TrimSpace(name[:i])), listPart("(%s)", name[i+1:len(name)-1], listGroup))
+	}
+
+	q.ctes = append(q.ctes, concatPart(head, wrapPart(" AS %s", subPart(sub))))
+	return q
+}
+
+// WithRecursive is With for an expression that refers to itself; it
+// turns the whole WITH clause into WITH RECURSIVE.
+func (q *QuerySet) WithRecursive(name string, sub *QuerySet) *QuerySet {
+	q.recursive = true
+	return q.With(name, sub)
+}
+
 func (q *QuerySet) InsertTable(table string) *QuerySet {
-	q.set[QINSERTTABLE] = fmt.Sprintf(" %s `%s` ", QINSERTTABLE[1:], table)
+	q.set[QINSERTTABLE] = identPart(" "+QINSERTTABLE[1:]+" %s ", table)
 	return q
 }
 
+// InsertIgnoreTable starts an insert that skips rows conflicting with a
+// unique key, and ReplaceTable one that deletes the conflicting rows
+// first. Building fails for dialects without the statement.
+func (q *QuerySet) InsertIgnoreTable(table string) *QuerySet {
+	return q.insertTable(InsertIgnore, table)
+}
+
+func (q *QuerySet) ReplaceTable(table string) *QuerySet {
+	return q.insertTable(InsertReplace, table)
+}
+
+func (q *QuerySet) insertTable(mode InsertMode, table string) *QuerySet {
+	q.set[QINSERTTABLE] = qpart{render: func(d Dialect) (string, []interface{}, error) {
+
+		verb, err := d.InsertInto(mode)
+		if err != nil {
+			return "", nil, err
+		}
+
+		name, err := quoteIdent(d, table)
+		if err != nil {
+			return "", nil, err
+		}
+
+		return fmt.Sprintf(" %s %s ", verb, name), nil, nil
+	}}
+	return q
+}
+
+// Upsert turns the insert into an update of the update columns, set to
+// the inserted values, when a row conflicts on the conflict columns. With
+// no update columns conflicting rows are left as they are. mysql checks
+// every unique key and ignores conflict; sqlite3 and postgres need it to
+// update, and fail the build with ErrUnsupported without it.
+func (q *QuerySet) Upsert(conflict []string, update ...string) *QuerySet {
+	return q.UpsertSet(conflict, nil, update...)
+}
+
+// UpsertSet is Upsert that also assigns the columns of set, in key order,
+// after the update columns. A Cond value, such as Expr("hits + 1"), is
+// written as it is with its args bound, so it can refer to the row being
+// updated; any other value is bound as UpdateMap binds it.
+func (q *QuerySet) UpsertSet(conflict []string, set map[string]interface{}, update ...string) *QuerySet {
+
+	q.set[QUPSERT] = qpart{render: func(d Dialect) (string, []interface{}, error) {
+
+		for _, col := range conflict {
+			if err := checkIdent(d, col); err != nil {
+				return "", nil, err
+			}
+		}
+
+		var (
+			args []interface{}
+			sets []string
+		)
+
+		for _, col := range update {
+
+			name, err := quoteIdent(d, col)
+			if err != nil {
+				return "", nil, err
+			}
+			sets = append(sets, name+"="+d.Inserted(name))
+		}
+
+		for _, c := range mapColumnValues(set, columnOptions{}) {
+
+			name, err := quoteIdent(d, c.name)
+			if err != nil {
+				return "", nil, err
+			}
+
+			p := dataPart(c.name, c.value)
+			if cond, ok := c.value.(Cond); ok {
+				p = cond.p
+			}
+
+			text, a, err := p.build(d)
+			if err != nil {
+				return "", nil, err
+			}
+
+			sets = append(sets, name+"="+text)
+			args = append(args, a...)
+		}
+
+		sql, err := d.Upsert(conflict, sets)
+		if err != nil {
+			return "", nil, err
+		}
+
+		return " " + sql, args, nil
+	}}
+	return q
+}
+
+// InsertFields quotes the column names of its list as GroupBy does.
 func (q *QuerySet) InsertFields(fields string) *QuerySet {
-	q.set[QINSERTFIELDS] = fmt.Sprintf(" (%s) ", fields)
+	q.set[QINSERTFIELDS] = listPart(" (%s) ", fields, listGroup)
 	return q
 }
 
 func (q *QuerySet) InsertValues(values string) *QuerySet {
-	q.set[QINSERTVALUES] = fmt.Sprintf(" %s %s ", QINSERTVALUES[1:], values)
+	q.set[QINSERTVALUES] = rawPart(fmt.Sprintf(" %s %s ", QINSERTVALUES[1:], values))
 	return q
 }
 
 func (q *QuerySet) UpdateTable(table string) *QuerySet {
-	q.set[QUPDATE] = fmt.Sprintf(" %s `%s` ", QUPDATE[1:], table)
+	q.set[QUPDATE] = identPart(" "+QUPDATE[1:]+" %s ", table)
 	return q
 }
 
 func (q *QuerySet) UpdateSet(values string) *QuerySet {
-	q.set[QUPDATESET] = fmt.Sprintf(" %s %s ", QUPDATESET[1:], values)
+	q.set[QUPDATESET] = rawPart(fmt.Sprintf(" %s %s ", QUPDATESET[1:], values))
 	return q
 }
 
 func (q *QuerySet) Delete() *QuerySet {
-	q.set[QDELETE] = fmt.Sprintf(" %s ", QDELETE[1:])
+	q.set[QDELETE] = rawPart(fmt.Sprintf(" %s ", QDELETE[1:]))
 	return q
 }
 
+// Select takes the select list as written. Items that are plain column
+// names, optionally qualified or aliased with AS, are quoted for the
+// dialect; expressions are kept as they are.
 func (q *QuerySet) Select(fields string) *QuerySet {
-	q.set[QSELECT] = fmt.Sprintf(" %s %s ", QSELECT[1:], fields)
+	q.set[QSELECT] = listPart(" "+QSELECT[1:]+" %s ", fields, listSelect)
 	return q
 }
 
-func (q *QuerySet) From(table string) *QuerySet {
-	q.set[QFROM] = fmt.Sprintf(" %s `%s` ", QFROM[1:], table)
+// SelectExpr takes the select list as written, with nothing quoted or
+// checked. Its ? placeholders take args as Expr's do.
+func (q *QuerySet) SelectExpr(fields string, args ...interface{}) *QuerySet {
+	q.set[QSELECT] = wrapPart(" "+QSELECT[1:]+" %s ", Expr(fields, args...).p)
 	return q
 }
 
-func (q *QuerySet) FromAs(table, as string) *QuerySet {
-	q.set[QFROM] = fmt.Sprintf(" %s `%s`  AS %s ", QFROM[1:], table, as)
+// SelectSub appends the scalar subquery sub, named as, to the select
+// list.
+func (q *QuerySet) SelectSub(sub *QuerySet, as string) *QuerySet {
+
+	var (
+		prev, ok = q.set[QSELECT]
+		item     = concatPart(subPart(sub), identPart(" AS %s ", as))
+	)
+
+	q.set[QSELECT] = qpart{render: func(d Dialect) (string, []interface{}, error) {
+
+		sql, args := fmt.Sprintf(" %s ", QSELECT[1:]), []interface{}{}
+		if ok {
+
+			var err error
+			if sql, args, err = prev.build(d); err != nil {
+				return "", nil, err
+			}
+			sql = strings.TrimRight(sql, " ") + ", "
+		}
+
+		text, a, err := item.build(d)
+		if err != nil {
+			return "", nil, err
+		}
+
+		return sql + text, append(append([]interface{}{}, args...), a...), nil
+	}}
 	return q
 }
 
-func (q *QuerySet) InnerJoinAsOn(table, as, on string) *QuerySet {
-	q.set[QINNERJOIN] = fmt.Sprintf(" %s `%s` AS %s ON %s ", QINNERJOIN[1:], table, as, on)
+// From and FromAs take a table name or a *QuerySet subquery.
+func (q *QuerySet) From(table interface{}) *QuerySet {
+	q.set[QFROM] = wrapPart(" "+QFROM[1:]+" %s ", sourcePart(table))
 	return q
 }
 
-func (q *QuerySet) LeftJoinAsOn(table, as, on string) *QuerySet {
-	q.set[QLEFTJOIN] = fmt.Sprintf(" %s `%s` AS %s ON %s ", QLEFTJOIN[1:], table, as, on)
+func (q *QuerySet) FromAs(table interface{}, as string) *QuerySet {
+	q.set[QFROM] = concatPart(wrapPart(" "+QFROM[1:]+" %s", sourcePart(table)), identPart("  AS %s ", as))
 	return q
 }
 
-func (q *QuerySet) Where(name string) *QuerySet {
+func (q *QuerySet) InnerJoinAsOn(table, as, on string) *QuerySet {
+	return q.Join(JoinInner, table, as, on)
+}
+
+func (q *QuerySet) LeftJoinAsOn(table, as, on string) *QuerySet {
+	return q.Join(JoinLeft, table, as, on)
+}
 
-	if strings.ContainsAny(name, "=><") {
-		return q
+// Join adds a join after those already on q; joins render in the order
+// they were added. table is a table name or a *QuerySet subquery, as may
+// be empty, and on is a raw condition string or a Cond.
+func (q *QuerySet) Join(kind JoinKind, table interface{}, as string, on interface{}) *QuerySet {
+
+	var cond qpart
+	switch c := on.(type) {
+	case string:
+		cond = rawPart(c)
+		if strings.TrimSpace(c) == "" {
+			cond = errPart(fmt.Errorf("Invalid Join Condition:%q", c))
+		}
+	case Cond:
+		cond = c.p
+	default:
+		cond = errPart(fmt.Errorf("Invalid Join Condition:%T", on))
 	}
 
-	q.filters = append(q.filters, fmt.Sprintf(" %s %s ", QWHERE[1:], name))
+	q.joins = append(q.joins, joinPart(kind, table, as, wrapPart("ON %s", cond)))
 	return q
 }
 
-func (q *QuerySet) WhereFindInSet(value, name string) *QuerySet {
+func (q *QuerySet) JoinUsing(kind JoinKind, table interface{}, as string, columns ...string) *QuerySet {
 
-	if strings.ContainsAny(value, "=><") || strings.ContainsAny(name, "=><") {
-		return q
-	}
+	q.joins = append(q.joins, joinPart(kind, table, as, qpart{render: func(d Dialect) (string, []interface{}, error) {
+
+		cols := make([]string, len(columns))
+		for i, col := range columns {
 
-	q.filters = append(q.filters, fmt.Sprintf(" %s(\"%s\", %s) ", QWHEREFISET[1:], value, name))
+			var err error
+			if cols[i], err = quoteIdent(d, col); err != nil {
+				return "", nil, err
+			}
+		}
+
+		return "USING (" + strings.Join(cols, ",") + ")", nil, nil
+	}}))
 	return q
 }
 
-func (q *QuerySet) And(name string) *QuerySet {
-
-	if strings.ContainsAny(name, "=><") {
-		return q
-	}
+func (q *QuerySet) CrossJoin(table interface{}, as string) *QuerySet {
 
-	q.filters = append(q.filters, fmt.Sprintf(" %s %s ", QAND[1:], name))
+	q.joins = append(q.joins, joinPart(JoinCross, table, as, qpart{}))
 	return q
 }
 
-func (q *QuerySet) AndFindInSet(value, name string) *QuerySet {
-
-	if strings.ContainsAny(value, "=><") || strings.ContainsAny(name, "=><") {
-		return q
-	}
+// Where, And and Or take either a column name, to be followed by an
+// operator method such as Eq, or a complete Cond built with the package
+// level Eq, And, Or, Not and friends. A plain column name is quoted for
+// the dialect, "t.id" segment by segment.
+func (q *QuerySet) Where(expr interface{}) *QuerySet {
 
-	q.filters = append(q.filters, fmt.Sprintf(" %s(\"%s\", %s) ", QANDFISET[1:], value, name))
+	q.filter(QWHERE[1:], expr)
 	return q
 }
 
-func (q *QuerySet) AndFindInSetWithLeftBracket(value, name string) *QuerySet {
+// filter adds the filter for Where, And or Or, remembering where a column
+// name went.
+func (q *QuerySet) filter(keyword string, expr interface{}) {
 
-	if strings.ContainsAny(value, "=><") || strings.ContainsAny(name, "=><") {
-		return q
+	p, ok := condPart(keyword, expr)
+	if !ok {
+		return
 	}
 
-	q.filters = append(q.filters, fmt.Sprintf(" AND (FIND_IN_SET(\"%s\", %s) ", value, name))
-	return q
+	q.filters = append(q.filters, p)
+	if _, ok := expr.(string); ok {
+		q.column = filterColumn{keyword: keyword, index: len(q.filters) - 1}
+	}
 }
 
-func (q *QuerySet) Or(name string) *QuerySet {
+func (q *QuerySet) WhereFindInSet(value interface{}, name string) *QuerySet {
 
 	if strings.ContainsAny(name, "=><") {
 		return q
 	}
 
-	q.filters = append(q.filters, fmt.Sprintf(" %s %s ", QOR[1:], name))
+	q.filters = append(q.filters, findInSetPart(" WHERE %s ", name, value))
 	return q
 }
 
-func (q *QuerySet) OrFindInSet(value, name string) *QuerySet {
-
-	if strings.ContainsAny(value, "=><") || strings.ContainsAny(name, "=><") {
-		return q
-	}
+func (q *QuerySet) And(expr interface{}) *QuerySet {
 
-	q.filters = append(q.filters, fmt.Sprintf(" %s(\"%s\", %s) ", QORFISET[1:], value, name))
+	q.filter(QAND[1:], expr)
 	return q
 }
 
-func (q *QuerySet) OrFindInSetWithRightBracket(value, name string) *QuerySet {
+func (q *QuerySet) AndFindInSet(value interface{}, name string) *QuerySet {
 
-	if strings.ContainsAny(value, "=><") || strings.ContainsAny(name, "=><") {
+	if strings.ContainsAny(name, "=><") {
 		return q
 	}
 
-	q.filters = append(q.filters, fmt.Sprintf(" %s(\"%s\", %s)) ", QORFISET[1:], value, name))
+	q.filters = append(q.filters, findInSetPart(" AND %s ", name, value))
 	return q
 }
 
-func (q *QuerySet) In(name string) *QuerySet {
+// Deprecated: use And(Or(FindInSet(...), ...)).
+func (q *QuerySet) AndFindInSetWithLeftBracket(value interface{}, name string) *QuerySet {
 
 	if strings.ContainsAny(name, "=><") {
 		return q
 	}
 
-	q.filters = append(q.filters, fmt.Sprintf(" IN (%s) ", strings.Trim(name, ",")))
+	q.filters = append(q.filters, findInSetPart(" AND (%s ", name, value))
 	return q
 }
 
-func (q *QuerySet) NotIn(name string) *QuerySet {
-
-	if strings.ContainsAny(name, "=><") {
-		return q
-	}
+func (q *QuerySet) Or(expr interface{}) *QuerySet {
 
-	q.filters = append(q.filters, fmt.Sprintf(" NOT IN (%s) ", strings.Trim(name, ",")))
+	q.filter(QOR[1:], expr)
 	return q
 }
 
-func (q *QuerySet) Eq(name string) *QuerySet {
+func (q *QuerySet) OrFindInSet(value interface{}, name string) *QuerySet {
 
 	if strings.ContainsAny(name, "=><") {
 		return q
 	}
 
-	q.filters = append(q.filters, fmt.Sprintf(" = \"%s\" ", name))
+	q.filters = append(q.filters, findInSetPart(" OR %s ", name, value))
 	return q
 }
 
-func (q *QuerySet) EqNative(name string) *QuerySet {
+// Deprecated: use And(Or(FindInSet(...), ...)).
+func (q *QuerySet) OrFindInSetWithRightBracket(value interface{}, name string) *QuerySet {
 
 	if strings.ContainsAny(name, "=><") {
 		return q
 	}
 
-	q.filters = append(q.filters, fmt.Sprintf(" =%s ", name))
+	q.filters = append(q.filters, findInSetPart(" OR %s) ", name, value))
 	return q
 }
 
-func (q *QuerySet) Neq(name string) *QuerySet {
-
-	if strings.ContainsAny(name, "=><") {
-		return q
-	}
+// In and NotIn of no values are always false and always true, so they
+// replace the column the last Where, And or Or named with 1=0 or 1=1.
+func (q *QuerySet) In(values ...interface{}) *QuerySet {
+	return q.in(" IN %s ", false, values)
+}
 
-	q.filters = append(q.filters, fmt.Sprintf(" != \"%s\" ", name))
-	return q
+func (q *QuerySet) NotIn(values ...interface{}) *QuerySet {
+	return q.in(" NOT IN %s ", true, values)
 }
 
-func (q *QuerySet) NeqNative(name string) *QuerySet {
+func (q *QuerySet) in(format string, not bool, values []interface{}) *QuerySet {
 
-	if strings.ContainsAny(name, "=><") {
+	if len(values) == 0 && q.column.index >= 0 && q.column.index == len(q.filters)-1 {
+		q.filters[q.column.index] = qpart{sql: fmt.Sprintf(" %s %s ", q.column.keyword, emptyIn(not))}
+		q.column.index = -1
 		return q
 	}
 
-	q.filters = append(q.filters, fmt.Sprintf(" !=%s ", name))
+	q.filters = append(q.filters, inPart(format, values))
 	return q
 }
 
-func (q *QuerySet) Gt(name string) *QuerySet {
-
-	if strings.ContainsAny(name, "=><") {
-		return q
-	}
-
-	q.filters = append(q.filters, fmt.Sprintf(" > \"%s\" ", name))
+func (q *QuerySet) Eq(value interface{}) *QuerySet {
+	q.filters = append(q.filters, bindPart("=", value))
 	return q
 }
 
-func (q *QuerySet) Ge(name string) *QuerySet {
+func (q *QuerySet) EqNative(name string) *QuerySet {
 
 	if strings.ContainsAny(name, "=><") {
 		return q
 	}
 
-	q.filters = append(q.filters, fmt.Sprintf(" >= \"%s\" ", name))
+	q.filters = append(q.filters, listPart(" =%s ", name, listColumn))
+	return q
+}
+
+func (q *QuerySet) Neq(value interface{}) *QuerySet {
+	q.filters = append(q.filters, bindPart("!=", value))
 	return q
 }
 
-func (q *QuerySet) Lt(name string) *QuerySet {
+func (q *QuerySet) NeqNative(name string) *QuerySet {
 
 	if strings.ContainsAny(name, "=><") {
 		return q
 	}
 
-	q.filters = append(q.filters, fmt.Sprintf(" < \"%s\" ", name))
+	q.filters = append(q.filters, listPart(" !=%s ", name, listColumn))
 	return q
 }
 
-func (q *QuerySet) Le(name string) *QuerySet {
-
-	if strings.ContainsAny(name, "=><") {
-		return q
-	}
+func (q *QuerySet) Gt(value interface{}) *QuerySet {
+	q.filters = append(q.filters, bindPart(">", value))
+	return q
+}
 
-	q.filters = append(q.filters, fmt.Sprintf(" <= \"%s\" ", name))
+func (q *QuerySet) Ge(value interface{}) *QuerySet {
+	q.filters = append(q.filters, bindPart(">=", value))
 	return q
 }
 
-func (q *QuerySet) Like(name string) *QuerySet {
+func (q *QuerySet) Lt(value interface{}) *QuerySet {
+	q.filters = append(q.filters, bindPart("<", value))
+	return q
+}
 
-	if strings.ContainsAny(name, "=><") {
-		return q
-	}
+func (q *QuerySet) Le(value interface{}) *QuerySet {
+	q.filters = append(q.filters, bindPart("<=", value))
+	return q
+}
 
-	q.filters = append(q.filters, fmt.Sprintf(" LIKE \"%s\" ", name))
+func (q *QuerySet) Like(value interface{}) *QuerySet {
+	q.filters = append(q.filters, bindPart("LIKE", value))
 	return q
 }
 
 func (q *QuerySet) GroupBy(name string) *QuerySet {
-	q.set[QGROUPBY] = fmt.Sprintf(" %s %s", QGROUPBY[1:], name)
+	q.set[QGROUPBY] = listPart(" "+QGROUPBY[1:]+" %s", name, listGroup)
+	return q
+}
+
+// GroupByExpr takes the list as written, as SelectExpr does.
+func (q *QuerySet) GroupByExpr(expr string, args ...interface{}) *QuerySet {
+	q.set[QGROUPBY] = wrapPart(" "+QGROUPBY[1:]+" %s", Expr(expr, args...).p)
 	return q
 }
 
 func (q *QuerySet) Having(name string) *QuerySet {
-	q.set[QHAVING] = fmt.Sprintf(" %s %s", QHAVING[1:], name)
+	q.set[QHAVING] = rawPart(fmt.Sprintf(" %s %s", QHAVING[1:], name))
 	return q
 }
 
+// OrderBy quotes the plain column names of its list, each optionally
+// followed by ASC or DESC, as Select does.
 func (q *QuerySet) OrderBy(name string) *QuerySet {
-	q.set[QORDERBY] = fmt.Sprintf(" %s %s", QORDERBY[1:], name)
+	q.set[QORDERBY] = listPart(" "+QORDERBY[1:]+" %s", name, listOrder)
+	return q
+}
+
+// OrderByExpr takes the list as written, as SelectExpr does.
+func (q *QuerySet) OrderByExpr(expr string, args ...interface{}) *QuerySet {
+	q.set[QORDERBY] = wrapPart(" "+QORDERBY[1:]+" %s", Expr(expr, args...).p)
 	return q
 }
 
 func (q *QuerySet) Limit(offset, num uint64) *QuerySet {
-	q.set[QLIMIT] = fmt.Sprintf(" %s %d,%d", QLIMIT[1:], offset, num)
+	q.set[QLIMIT] = dialectPart(func(d Dialect) string {
+		return " " + d.Limit(offset, num)
+	})
 	return q
 }
 
 func (q *QuerySet) LimitString(limit string) *QuerySet {
-	q.set[QLIMIT] = fmt.Sprintf(" %s %s", QLIMIT[1:], limit)
+	q.set[QLIMIT] = rawPart(fmt.Sprintf(" %s %s", QLIMIT[1:], limit))
+	return q
+}
+
+// Union, UnionAll, Intersect and Except add others to q as members of a
+// compound select. OrderBy and Limit on q then apply to the combined
+// result, so the members should set neither.
+func (q *QuerySet) Union(others ...*QuerySet) *QuerySet {
+	return q.compound(SetUnion, others)
+}
+
+func (q *QuerySet) UnionAll(others ...*QuerySet) *QuerySet {
+	return q.compound(SetUnionAll, others)
+}
+
+func (q *QuerySet) Intersect(others ...*QuerySet) *QuerySet {
+	return q.compound(SetIntersect, others)
+}
+
+func (q *QuerySet) Except(others ...*QuerySet) *QuerySet {
+	return q.compound(SetExcept, others)
+}
+
+func (q *QuerySet) compound(op SetOp, others []*QuerySet) *QuerySet {
+
+	for _, other := range others {
+
+		other := other
+		q.compounds = append(q.compounds, qpart{render: func(d Dialect) (string, []interface{}, error) {
+
+			if err := d.SetOp(op); err != nil {
+				return "", nil, err
+			}
+
+			sql, args, err := other.render(d)
+			if err != nil {
+				return "", nil, err
+			}
+
+			return fmt.Sprintf(" %s %s ", op, strings.TrimSpace(sql)), args, nil
+		}})
+	}
+
 	return q
 }
 
 func (q *QuerySet) sql() string {
+	sql, _, _ := q.bind(nil)
+	return sql
+}
+
+func (q *QuerySet) build(d Dialect) (string, []interface{}, error) {
+
+	sql, args, err := q.render(d)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return rebind(d, sql), args, nil
+}
+
+// render assembles the statement with ? placeholders, the form in which
+// it can be nested into another QuerySet.
+func (q *QuerySet) render(d Dialect) (string, []interface{}, error) {
 
 	var (
-		sql     string
-		qss     = qscores{}
-		filters = strings.Replace(strings.Join(q.filters, " "), "\"?\"", "?", -1)
+		sql  string
+		args []interface{}
+		qss  = qscores{}
 	)
 
+	if len(q.ctes) > 0 {
+
+		ctes := make([]string, len(q.ctes))
+		for i, v := range q.ctes {
+
+			var (
+				a   []interface{}
+				err error
+			)
+
+			if ctes[i], a, err = v.build(d); err != nil {
+				return "", nil, err
+			}
+			args = append(args, a...)
+		}
+
+		sql = " WITH "
+		if q.recursive {
+			sql += "RECURSIVE "
+		}
+		sql += strings.Join(ctes, ", ") + " "
+	}
+
 	for k, v := range q.set {
 
 		score, _ := strconv.Atoi(fmt.Sprintf("%d", k[0]))
@@ -349,28 +718,321 @@ func (q *QuerySet) sql() string {
 		})
 	}
 
+	joins, err := renderParts(d, q.joins, "")
+	if err != nil {
+		return "", nil, err
+	}
+
+	filters, err := renderParts(d, q.filters, " ")
+	if err != nil {
+		return "", nil, err
+	}
+
+	compounds, err := renderParts(d, q.compounds, "")
+	if err != nil {
+		return "", nil, err
+	}
+
+	// The compound members go after HAVING, which was added first, and
+	// before ORDER BY and LIMIT.
 	qss = append(qss, qscore{
+		score: 0x32,
+		value: joins,
+	}, qscore{
 		score: 0x35,
 		value: filters,
+	}, qscore{
+		score: 0x37,
+		value: compounds,
 	})
 
-	sort.Sort(qss)
+	sort.Stable(qss)
 
 	for _, v := range qss {
 
-		sql += v.value
+		text, a, err := v.value.build(d)
+		if err != nil {
+			return "", nil, err
+		}
+
+		sql += text
+		args = append(args, a...)
 	}
 
-	return sql
+	return sql, args, nil
+}
+
+// Build returns the statement text with placeholders and the ordered
+// arguments to send with it. Values recorded by the condition methods are
+// bound in place; args fill the positions left open, by Param or by a ?
+// written into the SQL text, in order, and any left over are appended.
+// It fails when q uses something the dialect cannot express.
+func (q *QuerySet) Build(args ...interface{}) (string, []interface{}, error) {
+	return q.bind(nil, args...)
+}
+
+// bind is Build for a Server whose dialect is d. A dialect pinned with
+// UseDialect takes precedence, and mysql is used when neither is set.
+func (q *QuerySet) bind(d Dialect, args ...interface{}) (string, []interface{}, error) {
+
+	if q.dialect != nil {
+		d = q.dialect
+	}
+
+	if d == nil {
+		d = DialectMySQL
+	}
+
+	sql, bound, err := q.build(d)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var rst []interface{}
+	for _, v := range bound {
+
+		if _, ok := v.(qplaceholder); ok {
+
+			if len(args) > 0 {
+				rst = append(rst, args[0])
+				args = args[1:]
+			}
+			continue
+		}
+
+		rst = append(rst, v)
+	}
+
+	return sql, append(rst, args...), nil
 }
 
 func (q *QuerySet) Sql() {
-	fmt.Printf("sql:%s\n", q.sql())
+
+	sql, args, err := q.Build()
+	if err != nil {
+		fmt.Printf("err:%v\n", err)
+		return
+	}
+
+	fmt.Printf("sql:%s args:%v\n", sql, args)
+}
+
+func (p qpart) build(d Dialect) (string, []interface{}, error) {
+
+	if p.render != nil {
+		return p.render(d)
+	}
+
+	return p.sql, p.args, nil
+}
+
+// renderParts renders parts one after another, with sep between them.
+func renderParts(d Dialect, parts []qpart, sep string) (qpart, error) {
+
+	var rst qpart
+	for i, v := range parts {
+
+		if i > 0 {
+			rst.sql += sep
+		}
+
+		text, a, err := v.build(d)
+		if err != nil {
+			return qpart{}, err
+		}
+
+		rst.sql += text
+		rst.args = append(rst.args, a...)
+	}
+
+	return rst, nil
+}
+
+// concatPart renders parts one after another as one part.
+func concatPart(parts ...qpart) qpart {
+	return qpart{render: func(d Dialect) (string, []interface{}, error) {
+
+		p, err := renderParts(d, parts, "")
+		if err != nil {
+			return "", nil, err
+		}
+
+		return p.sql, p.args, nil
+	}}
+}
+
+// errPart fails the build of any statement it is part of with err.
+func errPart(err error) qpart {
+	return qpart{render: func(d Dialect) (string, []interface{}, error) {
+		return "", nil, err
+	}}
+}
+
+// rawPart wraps a caller-written fragment. Every ? in it is a position
+// whose value is supplied at execution time.
+func rawPart(s string) qpart {
+
+	p := qpart{sql: s}
+	scanPlaceholders(s, func(int) {
+		p.args = append(p.args, Param)
+	})
+
+	return p
+}
+
+// dialectPart defers rendering until the dialect is known. The rendered
+// text must hold exactly one ? per arg.
+func dialectPart(render func(d Dialect) string, args ...interface{}) qpart {
+	return qpart{render: func(d Dialect) (string, []interface{}, error) {
+		return render(d), args, nil
+	}}
+}
+
+// wrapPart renders p inside format, which holds one %s.
+func wrapPart(format string, p qpart) qpart {
+	return qpart{render: func(d Dialect) (string, []interface{}, error) {
+
+		sql, args, err := p.build(d)
+		if err != nil {
+			return "", nil, err
+		}
+
+		return fmt.Sprintf(format, sql), args, nil
+	}}
+}
+
+// subPart nests sub as a parenthesized subquery. sub is rendered when the
+// outer statement is, so later changes to it are picked up.
+func subPart(sub *QuerySet) qpart {
+	return qpart{render: func(d Dialect) (string, []interface{}, error) {
+
+		sql, args, err := sub.render(d)
+		if err != nil {
+			return "", nil, err
+		}
+
+		return "(" + strings.TrimSpace(sql) + ")", args, nil
+	}}
+}
+
+// sourcePart renders a table name, quoted for the dialect, or a
+// subquery.
+func sourcePart(table interface{}) qpart {
+
+	if sub, ok := table.(*QuerySet); ok {
+		return subPart(sub)
+	}
+
+	return identPart("%s", fmt.Sprint(table))
+}
+
+// joinPart renders a join of table followed by tail, its ON or USING
+// clause.
+func joinPart(kind JoinKind, table interface{}, as string, tail qpart) qpart {
+
+	src := sourcePart(table)
+
+	return qpart{render: func(d Dialect) (string, []interface{}, error) {
+
+		if err := d.Join(kind); err != nil {
+			return "", nil, err
+		}
+
+		sql, args, err := src.build(d)
+		if err != nil {
+			return "", nil, err
+		}
+
+		sql = fmt.Sprintf(" %s %s ", kind, sql)
+
+		if as != "" {
+
+			alias, err := quoteIdent(d, as)
+			if err != nil {
+				return "", nil, err
+			}
+			sql += fmt.Sprintf("AS %s ", alias)
+		}
+
+		text, a, err := tail.build(d)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if text != "" {
+			sql += text + " "
+		}
+
+		return sql, append(append([]interface{}{}, args...), a...), nil
+	}}
+}
+
+// valuePart renders value as a bound ?, or as a subquery when it is a
+// *QuerySet.
+func valuePart(value interface{}) qpart {
+
+	if sub, ok := value.(*QuerySet); ok {
+		return subPart(sub)
+	}
+
+	return qpart{sql: "?", args: []interface{}{value}}
+}
+
+// bindPart compares against value with the operator op.
+func bindPart(op string, value interface{}) qpart {
+	return wrapPart(" "+op+" %s ", valuePart(value))
+}
+
+// findInSetPart tests value against the set column name, with the test
+// rendered by the dialect into the %s of format.
+func findInSetPart(format, name string, value interface{}) qpart {
+	return qpart{render: func(d Dialect) (string, []interface{}, error) {
+
+		col, err := quoteList(d, name, listColumn)
+		if err != nil {
+			return "", nil, err
+		}
+
+		return fmt.Sprintf(format, d.FindInSet(col)), []interface{}{value}, nil
+	}}
+}
+
+// inPart renders an IN list with one placeholder per value into the %s
+// of format. A single *QuerySet is nested as a subquery; any other value,
+// a string with commas included, is bound as one item.
+func inPart(format string, values []interface{}) qpart {
+
+	if len(values) == 1 {
+		if sub, ok := values[0].(*QuerySet); ok {
+			return wrapPart(format, subPart(sub))
+		}
+	}
+
+	if len(values) == 0 {
+		return qpart{sql: fmt.Sprintf(format, "(NULL)")}
+	}
+
+	p := qpart{sql: fmt.Sprintf(format, "("+strings.TrimSuffix(strings.Repeat("?,", len(values)), ",")+")")}
+	for _, v := range values {
+		p.args = append(p.args, v)
+	}
+
+	return p
+}
+
+// emptyIn is the constant an IN, or with not a NOT IN, of no values
+// stands for.
+func emptyIn(not bool) string {
+
+	if not {
+		return "1=1"
+	}
+	return "1=0"
 }
 
 type qscore struct {
 	score int
-	value string
+	value qpart
 }
 
 type qscores []qscore
@@ -0,0 +1,85 @@
+// Copyright 2016 The Sqlcl Author. All Rights Reserved.
+//
+// -----------------------------------------------------
+
+package sqlcl
+
+import (
+	"context"
+	"database/sql"
+	"math/rand"
+	"time"
+)
+
+type RetryPolicy struct {
+	MaxAttempts int              // total attempts, default 3
+	MinBackoff  time.Duration    // wait before the second attempt, default 10ms
+	MaxBackoff  time.Duration    // upper bound of the doubling wait, default 1s
+	Retryable   func(error) bool // default IsRetryable
+	TxOptions   *sql.TxOptions
+}
+
+// IsRetryable reports whether err aborted a transaction in a way that
+// running it again can fix: mysql deadlocks and lock wait timeouts, and
+// sqlite3 SQLITE_BUSY/SQLITE_LOCKED.
+func IsRetryable(err error) bool {
+
+	switch Classify(err) {
+	case KindDeadlock, KindLockTimeout:
+		return true
+	}
+
+	return false
+}
+
+// WithTxRetry runs fn in a transaction like WithTx, starting over in a
+// new transaction while the error is retryable and attempts remain. fn
+// must therefore be safe to run more than once.
+func (s *Server) WithTxRetry(ctx context.Context, p RetryPolicy, fn func(*Tx) error) error {
+
+	if p.MaxAttempts < 1 {
+		p.MaxAttempts = 3
+	}
+	if p.MinBackoff <= 0 {
+		p.MinBackoff = 10 * time.Millisecond
+	}
+	if p.MaxBackoff < p.MinBackoff {
+		p.MaxBackoff = time.Second
+	}
+	if p.Retryable == nil {
+		p.Retryable = IsRetryable
+	}
+
+	for attempt := 1; ; attempt++ {
+
+		err := s.WithTxOptions(ctx, p.TxOptions, fn)
+		if err == nil || attempt >= p.MaxAttempts || !p.Retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(p.backoff(attempt))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff doubles MinBackoff per attempt up to MaxBackoff and picks a
+// random wait in the upper half, so colliding writers spread out.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+
+	d := p.MinBackoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
@@ -0,0 +1,295 @@
+// Copyright 2016 The Sqlcl Author. All Rights Reserved.
+//
+// -----------------------------------------------------
+
+package sqlcl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+type structField struct {
+	name  string
+	index []int
+}
+
+// QueryInto runs q and fills dest, which must be a *[]T, *[]*T or *T
+// where T is a struct. Columns map to fields by their `db:"column"` tag,
+// or the lower-cased field name when untagged; `db:"-"` skips a field.
+// Use pointer or sql.Scanner fields for nullable columns.
+func (s *Server) QueryInto(q *QuerySet, dest interface{}, args ...interface{}) error {
+	return s.QueryIntoContext(context.Background(), q, dest, args...)
+}
+
+func (s *Server) QueryIntoContext(ctx context.Context, q *QuerySet, dest interface{}, args ...interface{}) error {
+
+	sql, args, err := q.bind(s.dialect, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.db.QueryContext(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+
+	return scanInto(rows, dest, s.strictScan)
+}
+
+func (s *Server) TxQueryInto(q *QuerySet, dest interface{}, args ...interface{}) error {
+	return s.TxQueryIntoContext(context.Background(), q, dest, args...)
+}
+
+func (s *Server) TxQueryIntoContext(ctx context.Context, q *QuerySet, dest interface{}, args ...interface{}) error {
+
+	if q.tx == nil {
+		return ErrNoTransaction
+	}
+
+	sql, args, err := q.bind(s.dialect, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := q.tx.QueryContext(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+
+	return scanInto(rows, dest, s.strictScan)
+}
+
+// scanInto reads every row into dest. In strict mode a result column with
+// no matching field, or a mapped field with no matching column, is an
+// error; otherwise such columns are discarded and such fields left as is.
+func scanInto(rows *sql.Rows, dest interface{}, strict bool) error {
+
+	defer rows.Close()
+
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return fmt.Errorf("Invalid Dest:%T", dest)
+	}
+	dv = dv.Elem()
+
+	var (
+		slice   = dv.Kind() == reflect.Slice
+		elem    = dv.Type()
+		elemPtr = false
+	)
+
+	if slice {
+		elem = elem.Elem()
+	}
+
+	if elem.Kind() == reflect.Ptr {
+		elem, elemPtr = elem.Elem(), true
+	}
+
+	if elem.Kind() != reflect.Struct || (!slice && elemPtr) {
+		return fmt.Errorf("Invalid Dest:%T", dest)
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	indexes, err := mapColumns(columns, structFields(elem, nil), strict)
+	if err != nil {
+		return err
+	}
+
+	var (
+		found    = false
+		row_dest = make([]interface{}, len(columns))
+	)
+
+	if slice {
+		dv.Set(reflect.MakeSlice(dv.Type(), 0, 0))
+	}
+
+	for rows.Next() {
+
+		item := reflect.New(elem)
+
+		for i, index := range indexes {
+
+			if index == nil {
+				row_dest[i] = new(interface{})
+				continue
+			}
+
+			row_dest[i] = fieldByIndex(item.Elem(), index).Addr().Interface()
+		}
+
+		if err := rows.Scan(row_dest...); err != nil {
+			return err
+		}
+
+		found = true
+
+		if !slice {
+			dv.Set(item.Elem())
+			break
+		}
+
+		if elemPtr {
+			dv.Set(reflect.Append(dv, item))
+		} else {
+			dv.Set(reflect.Append(dv, item.Elem()))
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if !slice && !found {
+		return ErrNoRows
+	}
+
+	return nil
+}
+
+func mapColumns(columns []string, fields []structField, strict bool) ([][]int, error) {
+
+	var (
+		indexes = make([][]int, len(columns))
+		byName  = make(map[string][]int, len(fields))
+		used    = make(map[string]bool, len(fields))
+	)
+
+	for _, f := range fields {
+		byName[f.name] = f.index
+	}
+
+	for i, col := range columns {
+
+		index, ok := byName[col]
+		if !ok {
+			index, ok = byName[strings.ToLower(col)]
+		}
+
+		if !ok {
+			if strict {
+				return nil, fmt.Errorf("Field Not Found:%s", col)
+			}
+			continue
+		}
+
+		indexes[i] = index
+		used[col], used[strings.ToLower(col)] = true, true
+	}
+
+	if strict {
+		for _, f := range fields {
+			if !used[f.name] {
+				return nil, fmt.Errorf("Column Not Found:%s", f.name)
+			}
+		}
+	}
+
+	return indexes, nil
+}
+
+// structFields lists the columns t can receive. Fields of embedded
+// structs are promoted unless shadowed by a field of the outer struct.
+func structFields(t reflect.Type, parent []int) []structField {
+
+	var (
+		fields   []structField
+		embedded []reflect.StructField
+		seen     = map[string]bool{}
+	)
+
+	for i := 0; i < t.NumField(); i++ {
+
+		f := t.Field(i)
+		tag := f.Tag.Get("db")
+		if tag == "-" || (f.PkgPath != "" && !f.Anonymous) {
+			continue
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if f.Anonymous && tag == "" && ft.Kind() == reflect.Struct && !isScanLeaf(ft) {
+
+			if f.PkgPath == "" || f.Type.Kind() != reflect.Ptr {
+				embedded = append(embedded, f)
+			}
+			continue
+		}
+
+		if f.PkgPath != "" {
+			continue
+		}
+
+		if tag == "" {
+			tag = strings.ToLower(f.Name)
+		}
+
+		seen[tag] = true
+		fields = append(fields, structField{
+			name:  tag,
+			index: append(append([]int{}, parent...), i),
+		})
+	}
+
+	for _, f := range embedded {
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		for _, sf := range structFields(ft, append(append([]int{}, parent...), f.Index...)) {
+
+			if seen[sf.name] {
+				continue
+			}
+
+			seen[sf.name] = true
+			fields = append(fields, sf)
+		}
+	}
+
+	return fields
+}
+
+func isScanLeaf(t reflect.Type) bool {
+	return t == timeType || reflect.PtrTo(t).Implements(scannerType)
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex, allocating nil embedded
+// struct pointers on the way down.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+
+	for i, x := range index {
+
+		if i > 0 && v.Kind() == reflect.Ptr {
+
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+
+		v = v.Field(x)
+	}
+
+	return v
+}
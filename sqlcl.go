@@ -5,6 +5,7 @@
 package sqlcl
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strconv"
@@ -15,7 +16,7 @@ import (
 )
 
 type Config struct {
-	Driver      string // mysql/sqlite3
+	Driver      string // mysql/sqlite3/postgres, or a name passed to RegisterDriver
 	Addr        string // mysql:127.0.0.1:3306/sqlite3:/tmp/foo.db or :memory:
 	User        string
 	Pass        string
@@ -25,16 +26,87 @@ type Config struct {
 	MaxLifetime time.Duration
 	MaxIdleConn int
 	MaxConn     int
+	StrictScan  bool    // QueryInto fails on unmapped columns or fields
+	LenientRows bool    // Query skips rows that fail to scan, listing them in Result.Errors
+	Dialect     Dialect // overrides the driver's dialect, e.g. DialectMySQL8
 }
 
 type Server struct {
-	db *sql.DB
+	db          *sql.DB
+	dialect     Dialect
+	strictScan  bool
+	lenientRows bool
 }
 
 type RowColumn map[string]string
 
 type Result struct {
-	Data []*RowColumn
+	Columns []*Column // the columns in select order
+	Data    []*RowColumn
+	Values  [][]string  // Data in column order, keeping columns that share a name
+	Errors  []*RowError // rows skipped with Config.LenientRows
+}
+
+// Column describes a result column as the driver reports it. The Has
+// fields tell whether the driver knows the value next to them.
+type Column struct {
+	Name         string
+	DatabaseType string // e.g. "VARCHAR", "INT", as sql.ColumnType.DatabaseTypeName
+	Nullable     bool
+	HasNullable  bool
+	Length       int64 // for variable length text and binary types
+	HasLength    bool
+	Precision    int64 // for decimal types
+	Scale        int64
+	HasPrecision bool
+}
+
+func newColumn(ct *sql.ColumnType) *Column {
+
+	c := &Column{Name: ct.Name(), DatabaseType: ct.DatabaseTypeName()}
+	c.Nullable, c.HasNullable = ct.Nullable()
+	c.Length, c.HasLength = ct.Length()
+	c.Precision, c.Scale, c.HasPrecision = ct.DecimalSize()
+
+	return c
+}
+
+// Row returns the values of row i in column order, or nil when there is
+// no such row.
+func (r *Result) Row(i int) []string {
+	if r == nil || i < 0 || i >= len(r.Values) {
+		return nil
+	}
+
+	return r.Values[i]
+}
+
+// ColumnNames returns the names of r.Columns in order.
+func (r *Result) ColumnNames() []string {
+	if r == nil {
+		return nil
+	}
+
+	names := make([]string, len(r.Columns))
+	for i, c := range r.Columns {
+		names[i] = c.Name
+	}
+
+	return names
+}
+
+// RowError is the failure to read the row at Index, counting from 0.
+type RowError struct {
+	Index int
+	Err   error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("Row %d: %v", e.Index, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
 }
 
 func (r *RowColumn) Get(k string) string {
@@ -134,24 +206,12 @@ func (r *RowColumn) Float64(k string) float64 {
 
 func New(c Config) (*Server, error) {
 
-	dsn := ""
-
-	switch c.Driver {
-	case "mysql":
-		if len(c.Protocol) < 3 {
-			c.Protocol = "tcp"
-		}
-		dsn = fmt.Sprintf("%s:%s@%s(%s)/%s?%s", c.User, c.Pass, c.Protocol, c.Addr, c.DbName, c.Params)
-
-	case "sqlite3":
-		dsn = c.Addr
-
-	default:
-
-		return nil, fmt.Errorf("Unknow db driver:%s", c.Driver)
+	info, err := lookupDriver(c.Driver)
+	if err != nil {
+		return nil, err
 	}
 
-	db_link, err := sql.Open(c.Driver, dsn)
+	db_link, err := sql.Open(c.Driver, info.dsn(c))
 	if err != nil {
 		return nil, err
 	}
@@ -160,60 +220,97 @@ func New(c Config) (*Server, error) {
 	db_link.SetMaxIdleConns(c.MaxIdleConn)
 	db_link.SetMaxOpenConns(c.MaxConn)
 
-	return &Server{db: db_link}, nil
+	if c.Dialect != nil {
+		info.dialect = c.Dialect
+	}
+
+	return &Server{
+		db:          db_link,
+		dialect:     info.dialect,
+		strictScan:  c.StrictScan,
+		lenientRows: c.LenientRows,
+	}, nil
 }
 
 func (s *Server) Close() error {
 	return s.db.Close()
 }
 
+func (s *Server) Dialect() Dialect {
+	return s.dialect
+}
+
 func (s *Server) Ping() error {
-	return s.db.Ping()
+	return s.PingContext(context.Background())
+}
+
+func (s *Server) PingContext(ctx context.Context) error {
+	return s.db.PingContext(ctx)
 }
 
 func (s *Server) QueryString(sql string) (*Result, error) {
+	return s.QueryStringContext(context.Background(), sql)
+}
 
-	rows, err := s.db.Query(sql)
+func (s *Server) QueryStringContext(ctx context.Context, sql string) (*Result, error) {
+
+	rows, err := s.db.QueryContext(ctx, sql)
 	if err != nil {
 		return nil, err
 	}
 
-	return parseRows(rows)
+	return parseRows(rows, s.lenientRows)
 }
 
 func (s *Server) Query(q *QuerySet, args ...interface{}) (*Result, error) {
+	return s.QueryContext(context.Background(), q, args...)
+}
+
+func (s *Server) QueryContext(ctx context.Context, q *QuerySet, args ...interface{}) (*Result, error) {
+
+	sql, args, err := q.bind(s.dialect, args...)
+	if err != nil {
+		return nil, err
+	}
 
-	rows, err := s.db.Query(q.sql(), args...)
+	rows, err := s.db.QueryContext(ctx, sql, args...)
 	if err != nil {
 		return nil, err
 	}
 
-	return parseRows(rows)
+	return parseRows(rows, s.lenientRows)
 }
 
 func (s *Server) QueryRow(q *QuerySet, args ...interface{}) (*RowColumn, error) {
+	return s.QueryRowContext(context.Background(), q, args...)
+}
 
-	rows, err := s.db.Query(q.sql(), args...)
-	if err != nil {
-		return nil, err
-	}
+func (s *Server) QueryRowContext(ctx context.Context, q *QuerySet, args ...interface{}) (*RowColumn, error) {
 
-	rst, err := parseRows(rows)
+	rst, err := s.QueryContext(ctx, q, args...)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(rst.Data) < 1 {
-		return nil, fmt.Errorf("Not Found")
+		return nil, ErrNoRows
 	}
 
-	return rst.Data[0], err
+	return rst.Data[0], nil
 }
 
 func (s *Server) Prepare(q *QuerySet) error {
+	return s.PrepareContext(context.Background(), q)
+}
 
-	var err error
-	q.stmt, err = s.db.Prepare(q.sql())
+func (s *Server) PrepareContext(ctx context.Context, q *QuerySet) error {
+
+	sql, _, err := q.bind(s.dialect)
+	if err != nil {
+		return err
+	}
+
+	q.stmt, err = s.db.PrepareContext(ctx, sql)
 	if err != nil {
 		return err
 	}
@@ -222,79 +319,76 @@ func (s *Server) Prepare(q *QuerySet) error {
 }
 
 func (s *Server) PrepareQuery(q *QuerySet, args ...interface{}) (*Result, error) {
+	return s.PrepareQueryContext(context.Background(), q, args...)
+}
 
-	if len(args) < 1 {
-		return nil, fmt.Errorf("No Args")
-	}
-
-	if q.stmt == nil {
-
-		var err error
-		q.stmt, err = s.db.Prepare(q.sql())
-		if err != nil {
-			return nil, err
-		}
-
-	}
+func (s *Server) PrepareQueryContext(ctx context.Context, q *QuerySet, args ...interface{}) (*Result, error) {
 
-	rows, err := q.stmt.Query(args...)
+	_, args, err := q.bind(s.dialect, args...)
 	if err != nil {
 		return nil, err
 	}
 
-	return parseRows(rows)
-}
-
-func (s *Server) PrepareQueryRow(q *QuerySet, args ...interface{}) (*RowColumn, error) {
-
 	if len(args) < 1 {
-		return nil, fmt.Errorf("No Args")
+		return nil, ErrNoArgs
 	}
 
 	if q.stmt == nil {
 
-		var err error
-		q.stmt, err = s.db.Prepare(q.sql())
-		if err != nil {
+		if err := s.PrepareContext(ctx, q); err != nil {
 			return nil, err
 		}
-
 	}
 
-	rows, err := q.stmt.Query(args...)
+	rows, err := q.stmt.QueryContext(ctx, args...)
 	if err != nil {
 		return nil, err
 	}
 
-	rst, err := parseRows(rows)
+	return parseRows(rows, s.lenientRows)
+}
+
+func (s *Server) PrepareQueryRow(q *QuerySet, args ...interface{}) (*RowColumn, error) {
+	return s.PrepareQueryRowContext(context.Background(), q, args...)
+}
+
+func (s *Server) PrepareQueryRowContext(ctx context.Context, q *QuerySet, args ...interface{}) (*RowColumn, error) {
+
+	rst, err := s.PrepareQueryContext(ctx, q, args...)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(rst.Data) < 1 {
-		return nil, fmt.Errorf("Not Found")
+		return nil, ErrNoRows
 	}
 
 	return rst.Data[0], nil
 }
 
 func (s *Server) PrepareExec(q *QuerySet, args ...interface{}) (sql.Result, error) {
+	return s.PrepareExecContext(context.Background(), q, args...)
+}
+
+func (s *Server) PrepareExecContext(ctx context.Context, q *QuerySet, args ...interface{}) (sql.Result, error) {
+
+	_, args, err := q.bind(s.dialect, args...)
+	if err != nil {
+		return nil, err
+	}
 
 	if len(args) < 1 {
-		return nil, fmt.Errorf("No Args")
+		return nil, ErrNoArgs
 	}
 
 	if q.stmt == nil {
 
-		var err error
-		q.stmt, err = s.db.Prepare(q.sql())
-		if err != nil {
+		if err := s.PrepareContext(ctx, q); err != nil {
 			return nil, err
 		}
-
 	}
 
-	return q.stmt.Exec(args...)
+	return q.stmt.ExecContext(ctx, args...)
 }
 
 func (s *Server) PrepareClose(q *QuerySet) {
@@ -305,18 +399,42 @@ func (s *Server) PrepareClose(q *QuerySet) {
 	}
 }
 
-func (s *Server) Exec(q *QuerySet) (sql.Result, error) {
-	return s.db.Exec(q.sql())
+func (s *Server) Exec(q *QuerySet, args ...interface{}) (sql.Result, error) {
+	return s.ExecContext(context.Background(), q, args...)
+}
+
+func (s *Server) ExecContext(ctx context.Context, q *QuerySet, args ...interface{}) (sql.Result, error) {
+
+	sql, args, err := q.bind(s.dialect, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.db.ExecContext(ctx, sql, args...)
 }
 
 func (s *Server) ExecString(sql string) (sql.Result, error) {
-	return s.db.Exec(sql)
+	return s.ExecStringContext(context.Background(), sql)
+}
+
+func (s *Server) ExecStringContext(ctx context.Context, sql string) (sql.Result, error) {
+	return s.db.ExecContext(ctx, sql)
 }
 
+// Deprecated: use Begin or WithTx, which are not tied to one QuerySet.
 func (s *Server) TxBegin(q *QuerySet) error {
+	return s.TxBeginContext(context.Background(), q, nil)
+}
+
+// TxBeginContext starts a transaction on q. opts may be nil or set the
+// isolation level and read-only mode; ctx bounds the whole transaction,
+// which is rolled back if ctx is done before TxCommit.
+//
+// Deprecated: use BeginTx or WithTxOptions.
+func (s *Server) TxBeginContext(ctx context.Context, q *QuerySet, opts *sql.TxOptions) error {
 
 	var err error
-	q.tx, err = s.db.Begin()
+	q.tx, err = s.db.BeginTx(ctx, opts)
 	if err != nil {
 		return err
 	}
@@ -324,58 +442,87 @@ func (s *Server) TxBegin(q *QuerySet) error {
 	return nil
 }
 
+// Deprecated: use Tx.Commit.
 func (s *Server) TxCommit(q *QuerySet) error {
 
 	if q.tx == nil {
-		return fmt.Errorf("Client Error")
+		return ErrNoTransaction
 	}
 
 	return q.tx.Commit()
 }
 
 func (s *Server) TxExec(q *QuerySet, args ...interface{}) (sql.Result, error) {
+	return s.TxExecContext(context.Background(), q, args...)
+}
+
+func (s *Server) TxExecContext(ctx context.Context, q *QuerySet, args ...interface{}) (sql.Result, error) {
 
 	if q.tx == nil {
-		return nil, fmt.Errorf("Client Error")
+		return nil, ErrNoTransaction
+	}
+
+	sql, args, err := q.bind(s.dialect, args...)
+	if err != nil {
+		return nil, err
 	}
 
-	return q.tx.Exec(q.sql(), args...)
+	return q.tx.ExecContext(ctx, sql, args...)
 }
 
 func (s *Server) TxPrepare(q *QuerySet) error {
+	return s.TxPrepareContext(context.Background(), q)
+}
+
+func (s *Server) TxPrepareContext(ctx context.Context, q *QuerySet) error {
 
 	if q.tx == nil {
-		return fmt.Errorf("Client Error")
+		return ErrNoTransaction
 	}
 
-	var err error
-	q.stmt, err = q.tx.Prepare(q.sql())
+	sql, _, err := q.bind(s.dialect)
+	if err != nil {
+		return err
+	}
+
+	q.stmt, err = q.tx.PrepareContext(ctx, sql)
 
 	return err
 }
 
 func (s *Server) TxPrepareExec(q *QuerySet, args ...interface{}) (sql.Result, error) {
+	return s.TxPrepareExecContext(context.Background(), q, args...)
+}
+
+func (s *Server) TxPrepareExecContext(ctx context.Context, q *QuerySet, args ...interface{}) (sql.Result, error) {
 
 	if q.tx == nil {
-		return nil, fmt.Errorf("Client Error")
+		return nil, ErrNoTransaction
 	}
 
 	if q.stmt == nil {
 
-		var err error
-		q.stmt, err = q.tx.Prepare(q.sql())
-		if err != nil {
+		if err := s.TxPrepareContext(ctx, q); err != nil {
 			return nil, err
 		}
 	}
 
-	return q.tx.Stmt(q.stmt).Exec(args...)
+	_, args, err := q.bind(s.dialect, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.tx.StmtContext(ctx, q.stmt).ExecContext(ctx, args...)
 }
 
 func (s *Server) TxPrepareClose(q *QuerySet) error {
 
-	if q.tx == nil || q.stmt == nil {
-		return fmt.Errorf("Client Error")
+	if q.tx == nil {
+		return ErrNoTransaction
+	}
+
+	if q.stmt == nil {
+		return ErrNoStatement
 	}
 
 	err := q.stmt.Close()
@@ -384,134 +531,257 @@ func (s *Server) TxPrepareClose(q *QuerySet) error {
 }
 
 func (s *Server) TxQuery(q *QuerySet, args ...interface{}) (*Result, error) {
+	return s.TxQueryContext(context.Background(), q, args...)
+}
+
+func (s *Server) TxQueryContext(ctx context.Context, q *QuerySet, args ...interface{}) (*Result, error) {
 
 	if q.tx == nil {
-		return nil, fmt.Errorf("Client Error")
+		return nil, ErrNoTransaction
+	}
+
+	sql, args, err := q.bind(s.dialect, args...)
+	if err != nil {
+		return nil, err
 	}
 
-	rows, err := q.tx.Query(q.sql(), args...)
+	rows, err := q.tx.QueryContext(ctx, sql, args...)
 	if err != nil {
 		return nil, err
 	}
 
-	return parseRows(rows)
+	return parseRows(rows, s.lenientRows)
 }
 
 func (s *Server) TxQueryRow(q *QuerySet, args ...interface{}) (*RowColumn, error) {
+	return s.TxQueryRowContext(context.Background(), q, args...)
+}
 
-	if q.tx == nil {
-		return nil, fmt.Errorf("Client Error")
-	}
-
-	rows, err := q.tx.Query(q.sql(), args...)
+func (s *Server) TxQueryRowContext(ctx context.Context, q *QuerySet, args ...interface{}) (*RowColumn, error) {
 
-	rst, err := parseRows(rows)
+	rst, err := s.TxQueryContext(ctx, q, args...)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(rst.Data) < 1 {
-		return nil, fmt.Errorf("Not Found")
+		return nil, ErrNoRows
 	}
 
-	return rst.Data[0], err
+	return rst.Data[0], nil
 }
 
+// Deprecated: use Tx.Rollback.
 func (s *Server) TxRollBack(q *QuerySet) error {
 
 	if q.tx == nil {
-		return fmt.Errorf("Client Error")
+		return ErrNoTransaction
 	}
 
 	return q.tx.Rollback()
 }
 
 func (s *Server) TxStmtQuery(q *QuerySet, args ...interface{}) (*Result, error) {
+	return s.TxStmtQueryContext(context.Background(), q, args...)
+}
 
-	if q.tx == nil || q.stmt == nil {
-		return nil, fmt.Errorf("Client Error")
+func (s *Server) TxStmtQueryContext(ctx context.Context, q *QuerySet, args ...interface{}) (*Result, error) {
+
+	if q.tx == nil {
+		return nil, ErrNoTransaction
+	}
+
+	if q.stmt == nil {
+		return nil, ErrNoStatement
+	}
+
+	_, args, err := q.bind(s.dialect, args...)
+	if err != nil {
+		return nil, err
 	}
 
-	rows, err := q.tx.Stmt(q.stmt).Query(args...)
+	rows, err := q.tx.StmtContext(ctx, q.stmt).QueryContext(ctx, args...)
 	if err != nil {
 		return nil, err
 	}
 
-	return parseRows(rows)
+	return parseRows(rows, s.lenientRows)
 }
 
 func (s *Server) TxStmtQueryRow(q *QuerySet, args ...interface{}) (*RowColumn, error) {
+	return s.TxStmtQueryRowContext(context.Background(), q, args...)
+}
 
-	if q.tx == nil || q.stmt == nil {
-		return nil, fmt.Errorf("Client Error")
-	}
-
-	rows, err := q.tx.Stmt(q.stmt).Query(args...)
-	if err != nil {
-		return nil, err
-	}
+func (s *Server) TxStmtQueryRowContext(ctx context.Context, q *QuerySet, args ...interface{}) (*RowColumn, error) {
 
-	rst, err := parseRows(rows)
+	rst, err := s.TxStmtQueryContext(ctx, q, args...)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(rst.Data) < 1 {
-		return nil, fmt.Errorf("Not Found")
+		return nil, ErrNoRows
 	}
 
-	return rst.Data[0], err
+	return rst.Data[0], nil
 }
 
 func (s *Server) TxStmtExec(q *QuerySet, args ...interface{}) (sql.Result, error) {
+	return s.TxStmtExecContext(context.Background(), q, args...)
+}
+
+func (s *Server) TxStmtExecContext(ctx context.Context, q *QuerySet, args ...interface{}) (sql.Result, error) {
 
-	if q.tx == nil || q.stmt == nil {
-		return nil, fmt.Errorf("Client Error")
+	if q.tx == nil {
+		return nil, ErrNoTransaction
 	}
 
-	return q.tx.Stmt(q.stmt).Exec(args...)
+	if q.stmt == nil {
+		return nil, ErrNoStatement
+	}
+
+	_, args, err := q.bind(s.dialect, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.tx.StmtContext(ctx, q.stmt).ExecContext(ctx, args...)
+}
+
+// parseRows reads every row of rows and closes it. A row that fails to
+// scan ends the read with a RowError, returned with the rows before it;
+// in lenient mode the row is skipped and its error kept in Result.Errors
+// instead. An error ending the iteration is returned either way.
+func parseRows(rows *sql.Rows, lenient bool) (*Result, error) {
+
+	rst, _, err := scanRows(rows, lenient, false)
+	return rst, err
 }
 
-func parseRows(rows *sql.Rows) (*Result, error) {
+// scanRows is parseRows that, with keep, also returns every row of the
+// result as the driver typed it, as QueryTyped reads it.
+func scanRows(rows *sql.Rows, lenient, keep bool) (*Result, [][]interface{}, error) {
+
+	defer rows.Close()
 
-	columes, err := rows.Columns()
+	types, err := rows.ColumnTypes()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var (
-		value    = ""
-		rst      = &Result{}
+		index    = 0
+		raw      [][]interface{}
+		rst      = &Result{Columns: make([]*Column, len(types))}
+		columes  = make([]string, len(types))
 		values   = make([]sql.RawBytes, len(columes))
+		kept     = make([]keptValue, len(columes))
 		row_dest = make([]interface{}, len(columes))
 	)
 
 	for i, _ := range values {
+
 		row_dest[i] = &values[i]
+		if keep {
+			kept[i].text = &values[i]
+			row_dest[i] = &kept[i]
+		}
+	}
+
+	for i, ct := range types {
+		rst.Columns[i] = newColumn(ct)
+		columes[i] = ct.Name()
 	}
 
-	for rows.Next() {
+	for ; rows.Next(); index++ {
 
 		err := rows.Scan(row_dest...)
 		if err != nil {
+
+			if !lenient {
+				return rst, raw, &RowError{Index: index, Err: err}
+			}
+
+			rst.Errors = append(rst.Errors, &RowError{Index: index, Err: err})
 			continue
 		}
 
-		rdt := &RowColumn{}
+		rdt, ordered := rowColumn(columes, values)
+		rst.Data = append(rst.Data, rdt)
+		rst.Values = append(rst.Values, ordered)
 
-		for i, col := range values {
+		if keep {
 
-			if col == nil {
-				value = "NULL"
-			} else {
-				value = string(col)
+			typed := make([]interface{}, len(kept))
+			for i, v := range kept {
+				typed[i] = typedValue(types[i], v.value)
 			}
+			raw = append(raw, typed)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return rst, raw, &RowError{Index: index, Err: err}
+	}
+
+	return rst, raw, nil
+}
+
+// keptValue scans a column both as the driver typed it and into text, the
+// text written as database/sql writes a sql.RawBytes.
+type keptValue struct {
+	value interface{}
+	text  *sql.RawBytes
+}
+
+func (k *keptValue) Scan(src interface{}) error {
+
+	var b []byte
 
-			(*rdt)[columes[i]] = value
+	switch v := src.(type) {
+	case nil:
+	case []byte:
+		b = append([]byte{}, v...)
+		src = b
+	case string:
+		b = []byte(v)
+	case time.Time:
+		b = v.AppendFormat(nil, time.RFC3339Nano)
+	case int64:
+		b = strconv.AppendInt(nil, v, 10)
+	case float64:
+		b = strconv.AppendFloat(nil, v, 'g', -1, 64)
+	case bool:
+		b = strconv.AppendBool(nil, v)
+	default:
+		b = []byte(fmt.Sprint(v))
+	}
+
+	k.value, *k.text = src, b
+	return nil
+}
+
+// rowColumn copies one scanned row, with NULL as the string "NULL", both
+// by name and in column order.
+func rowColumn(columes []string, values []sql.RawBytes) (*RowColumn, []string) {
+
+	var (
+		value   = ""
+		rdt     = &RowColumn{}
+		ordered = make([]string, len(values))
+	)
+
+	for i, col := range values {
+
+		if col == nil {
+			value = "NULL"
+		} else {
+			value = string(col)
 		}
 
-		rst.Data = append(rst.Data, rdt)
+		(*rdt)[columes[i]] = value
+		ordered[i] = value
 	}
 
-	return rst, nil
+	return rdt, ordered
 }
@@ -0,0 +1,26 @@
+// Copyright 2016 The Sqlcl Author. All Rights Reserved.
+//
+// -----------------------------------------------------
+
+//go:build cgo
+// +build cgo
+
+package sqlcl
+
+import (
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteErrorCode reports the primary and extended result codes carried
+// by a go-sqlite3 error.
+func sqliteErrorCode(err error) (int, int, bool) {
+
+	var e sqlite3.Error
+	if !errors.As(err, &e) {
+		return 0, 0, false
+	}
+
+	return int(e.Code), int(e.ExtendedCode), true
+}
@@ -0,0 +1,13 @@
+// Copyright 2016 The Sqlcl Author. All Rights Reserved.
+//
+// -----------------------------------------------------
+
+//go:build !cgo
+// +build !cgo
+
+package sqlcl
+
+// go-sqlite3 is a stub without cgo and never returns its own errors.
+func sqliteErrorCode(err error) (int, int, bool) {
+	return 0, 0, false
+}
@@ -5,8 +5,15 @@
 package sqlcl
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestSqlite3DB(t *testing.T) {
@@ -160,3 +167,1310 @@ func TestSqlite3Tx(t *testing.T) {
 
 	t.Logf("Tx.rst.len:%d err:%v\n", len(rst.Data), err)
 }
+
+func TestSqlite3BindArgs(t *testing.T) {
+
+	db, err := New(Config{
+		Driver:      "sqlite3",
+		Addr:        ":memory:",
+		MaxIdleConn: 1,
+		MaxConn:     1,
+	})
+	if err != nil {
+		t.Fatalf("db conn err:%s", err.Error())
+	}
+	defer db.Close()
+
+	if _, err = db.ExecString("create table bar(id integer not null primary key autoincrement, name text)"); err != nil {
+		t.Fatalf("Bind.#000 err:%v\n", err)
+	}
+
+	qset := NewQuerySet()
+	for _, name := range []string{"alice", "bob", "\" OR 1=1 --", "?", "Smith, John"} {
+
+		if _, err = db.Exec(qset.Clear().InsertTable("bar").InsertFields("name").InsertValues("(?)"), name); err != nil {
+			t.Fatalf("Bind.#001 err:%v\n", err)
+		}
+	}
+
+	rst, err := db.Query(qset.Clear().Select("*").From("bar").Where("name").Eq("\" OR 1=1 --"))
+	if err != nil {
+		t.Fatalf("Bind.#002 err:%v\n", err)
+	}
+
+	if len(rst.Data) != 1 || rst.Data[0].Int("id") != 3 {
+		t.Fatalf("Bind.#002 rst:%v\n", rst.Data)
+	}
+
+	rst, err = db.Query(qset.Clear().Select("*").From("bar").Where("id").In(1, 3).And("name").Neq(Param), "alice")
+	if err != nil {
+		t.Fatalf("Bind.#003 err:%v\n", err)
+	}
+
+	if len(rst.Data) != 1 || rst.Data[0].Int("id") != 3 {
+		t.Fatalf("Bind.#003 rst:%v\n", rst.Data)
+	}
+
+	// A "?" value is looked up as it is, and does not take the place of
+	// the call argument meant for the Param after it.
+	rst, err = db.Query(qset.Clear().Select("*").From("bar").Where("name").Eq("?").And("id").Gt(Param), 0)
+	if err != nil {
+		t.Fatalf("Bind.#004 err:%v\n", err)
+	}
+
+	if len(rst.Data) != 1 || rst.Data[0].Int("id") != 4 {
+		t.Fatalf("Bind.#004 rst:%v\n", rst.Data)
+	}
+
+	if _, args, _ := qset.Build(0); !reflect.DeepEqual(args, []interface{}{"?", 0}) {
+		t.Fatalf("Bind.#004 args:%v\n", args)
+	}
+
+	// A single value is one item of the list, commas and all.
+	rst, err = db.Query(qset.Clear().Select("*").From("bar").Where("name").In("Smith, John"))
+	if err != nil || len(rst.Data) != 1 || rst.Data[0].Int("id") != 5 {
+		t.Fatalf("Bind.#005 rst:%v err:%v\n", rst, err)
+	}
+
+	// No values: IN matches nothing and NOT IN everything.
+	for i, c := range []struct {
+		q    *QuerySet
+		rows int
+	}{
+		{NewQuerySet().Select("*").From("bar").Where("id").Gt(0).And("name").NotIn(), 5},
+		{NewQuerySet().Select("*").From("bar").Where("name").In().Or("id").Eq(1), 1},
+		{NewQuerySet().Select("*").From("bar").Where(And(NotIn("name"), Gt("id", 0))), 5},
+		{NewQuerySet().Select("*").From("bar").Where(In("name")), 0},
+	} {
+		if rst, err = db.Query(c.q); err != nil || len(rst.Data) != c.rows {
+			t.Fatalf("Bind.#%03d rst:%v err:%v\n", i+6, rst, err)
+		}
+	}
+
+	if sql, _, _ := NewQuerySet().Select("*").From("bar").Where("id").Gt(0).And("name").NotIn().Build(); strings.TrimSpace(sql) != "SELECT *  FROM `bar`  WHERE `id`   > ?   AND 1=1" {
+		t.Fatalf("Bind.#010 sql:%s\n", sql)
+	}
+}
+
+func TestSqlite3Typed(t *testing.T) {
+
+	db, err := New(Config{
+		Driver:      "sqlite3",
+		Addr:        ":memory:",
+		MaxIdleConn: 1,
+		MaxConn:     1,
+	})
+	if err != nil {
+		t.Fatalf("db conn err:%s", err.Error())
+	}
+	defer db.Close()
+
+	if _, err = db.ExecString(`create table typed(id integer not null primary key autoincrement,
+		name text, score real, flag boolean, created datetime, data blob)`); err != nil {
+		t.Fatalf("Typed.#000 err:%v\n", err)
+	}
+
+	qset := NewQuerySet().InsertTable("typed").InsertFields("name,score,flag,created,data").InsertValues("(?,?,?,?,?)")
+	if _, err = db.Exec(qset, "alice", 9.5, true, time.Date(2016, 5, 1, 10, 0, 0, 0, time.UTC), []byte{1, 2}); err != nil {
+		t.Fatalf("Typed.#001 err:%v\n", err)
+	}
+
+	if _, err = db.Exec(qset, nil, nil, nil, nil, nil); err != nil {
+		t.Fatalf("Typed.#001 err:%v\n", err)
+	}
+
+	rst, err := db.QueryTyped(NewQuerySet().Select("*").From("typed").OrderBy("id"))
+	if err != nil || len(rst.Data) != 2 {
+		t.Fatalf("Typed.#002 rst:%v err:%v\n", rst, err)
+	}
+
+	row := rst.Data[0]
+	if id, err := row.Int64("id"); err != nil || id != 1 {
+		t.Errorf("Typed.#003 id:%d err:%v", id, err)
+	}
+	if name, err := row.String("name"); err != nil || name != "alice" {
+		t.Errorf("Typed.#003 name:%s err:%v", name, err)
+	}
+	if score, err := row.Float64("score"); err != nil || score != 9.5 {
+		t.Errorf("Typed.#003 score:%v err:%v", score, err)
+	}
+	if flag, err := row.Bool("flag"); err != nil || !flag {
+		t.Errorf("Typed.#003 flag:%v err:%v", flag, err)
+	}
+	if created, err := row.Time("created"); err != nil || created.Year() != 2016 {
+		t.Errorf("Typed.#003 created:%v err:%v", created, err)
+	}
+	if data, err := row.Bytes("data"); err != nil || len(data) != 2 {
+		t.Errorf("Typed.#003 data:%v err:%v", data, err)
+	}
+	if _, err := row.Int64("name"); err == nil {
+		t.Errorf("Typed.#003 expected conversion error")
+	}
+	if _, err := row.Int64("missing"); err == nil {
+		t.Errorf("Typed.#003 expected missing column error")
+	}
+
+	row = rst.Data[1]
+	if !row.IsNull("name") || row.IsNull("id") {
+		t.Errorf("Typed.#004 null check failed")
+	}
+	if _, err := row.String("name"); err == nil {
+		t.Errorf("Typed.#004 expected null value error")
+	}
+}
+
+type scanBase struct {
+	ID int64 `db:"id"`
+}
+
+type scanItem struct {
+	scanBase
+	Name    string         `db:"name"`
+	Score   *float64       `db:"score"`
+	Comment sql.NullString `db:"comment"`
+	Ignored string         `db:"-"`
+}
+
+func TestSqlite3QueryInto(t *testing.T) {
+
+	db, err := New(Config{
+		Driver:      "sqlite3",
+		Addr:        ":memory:",
+		MaxIdleConn: 1,
+		MaxConn:     1,
+	})
+	if err != nil {
+		t.Fatalf("db conn err:%s", err.Error())
+	}
+	defer db.Close()
+
+	if _, err = db.ExecString(`create table item(id integer not null primary key autoincrement,
+		name text not null, score real, comment text, extra text)`); err != nil {
+		t.Fatalf("Into.#000 err:%v\n", err)
+	}
+
+	qset := NewQuerySet().InsertTable("item").InsertFields("name,score,comment").InsertValues("(?,?,?)")
+	if _, err = db.Exec(qset, "alice", 9.5, "ok"); err != nil {
+		t.Fatalf("Into.#001 err:%v\n", err)
+	}
+	if _, err = db.Exec(qset, "bob", nil, nil); err != nil {
+		t.Fatalf("Into.#001 err:%v\n", err)
+	}
+
+	var items []scanItem
+	if err = db.QueryInto(NewQuerySet().Select("*").From("item").OrderBy("id"), &items); err != nil {
+		t.Fatalf("Into.#002 err:%v\n", err)
+	}
+
+	if len(items) != 2 || items[0].ID != 1 || items[0].Name != "alice" || items[0].Score == nil ||
+		*items[0].Score != 9.5 || items[0].Comment.String != "ok" {
+		t.Fatalf("Into.#002 items:%+v\n", items)
+	}
+
+	if items[1].Score != nil || items[1].Comment.Valid {
+		t.Fatalf("Into.#002 null items:%+v\n", items[1])
+	}
+
+	var item *scanItem
+	if err = db.QueryInto(NewQuerySet().Select("*").From("item").Where("id").Eq(2), &item); err == nil {
+		t.Fatalf("Into.#003 expected invalid dest error")
+	}
+
+	var one scanItem
+	if err = db.QueryInto(NewQuerySet().Select("*").From("item").Where("id").Eq(2), &one); err != nil || one.Name != "bob" {
+		t.Fatalf("Into.#003 one:%+v err:%v\n", one, err)
+	}
+
+	if err = db.QueryInto(NewQuerySet().Select("*").From("item").Where("id").Eq(3), &one); err == nil {
+		t.Fatalf("Into.#004 expected not found error")
+	}
+
+	db.strictScan = true
+
+	if err = db.QueryInto(NewQuerySet().Select("*").From("item"), &items); err == nil {
+		t.Fatalf("Into.#005 expected unmapped column error")
+	}
+
+	if err = db.QueryInto(NewQuerySet().Select("id,name").From("item"), &items); err == nil {
+		t.Fatalf("Into.#005 expected missing column error")
+	}
+}
+
+func TestSqlite3Context(t *testing.T) {
+
+	db, err := New(Config{
+		Driver:      "sqlite3",
+		Addr:        ":memory:",
+		MaxIdleConn: 1,
+		MaxConn:     1,
+	})
+	if err != nil {
+		t.Fatalf("db conn err:%s", err.Error())
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	if _, err = db.ExecStringContext(ctx, "create table ctx(id integer not null primary key autoincrement, name text)"); err != nil {
+		t.Fatalf("Ctx.#000 err:%v\n", err)
+	}
+
+	qset := NewQuerySet()
+	if err = db.TxBeginContext(ctx, qset, &sql.TxOptions{Isolation: sql.LevelSerializable}); err != nil {
+		t.Fatalf("Ctx.#001 err:%v\n", err)
+	}
+
+	if _, err = db.TxExecContext(ctx, qset.InsertTable("ctx").InsertFields("name").InsertValues("(?)"), "alice"); err != nil {
+		t.Fatalf("Ctx.#001 err:%v\n", err)
+	}
+
+	if err = db.TxCommit(qset); err != nil {
+		t.Fatalf("Ctx.#001 err:%v\n", err)
+	}
+
+	row, err := db.QueryRowContext(ctx, qset.Clear().Select("*").From("ctx").Where("name").Eq("alice"))
+	if err != nil || row.Int("id") != 1 {
+		t.Fatalf("Ctx.#002 row:%v err:%v\n", row, err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	if _, err = db.QueryContext(cancelled, qset); err == nil {
+		t.Fatalf("Ctx.#003 expected context canceled error")
+	}
+}
+
+func TestSqlite3WithTx(t *testing.T) {
+
+	db, err := New(Config{
+		Driver:      "sqlite3",
+		Addr:        ":memory:",
+		MaxIdleConn: 1,
+		MaxConn:     1,
+	})
+	if err != nil {
+		t.Fatalf("db conn err:%s", err.Error())
+	}
+	defer db.Close()
+
+	if _, err = db.ExecString("create table wtx(id integer not null primary key autoincrement, name text)"); err != nil {
+		t.Fatalf("WithTx.#000 err:%v\n", err)
+	}
+
+	var (
+		ctx    = context.Background()
+		insert = NewQuerySet().InsertTable("wtx").InsertFields("name").InsertValues("(?)")
+		count  = NewQuerySet().Select("COUNT(*) AS num").From("wtx")
+	)
+
+	err = db.WithTx(ctx, func(tx *Tx) error {
+
+		for i := 0; i < 10; i++ {
+			if _, err := tx.PrepareExec(insert, fmt.Sprintf("#%d_with_tx", i)); err != nil {
+				return err
+			}
+		}
+		tx.PrepareClose(insert)
+
+		row, err := tx.QueryRow(count)
+		if err != nil {
+			return err
+		}
+
+		if row.Int("num") != 10 {
+			return fmt.Errorf("tx count:%d", row.Int("num"))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx.#001 err:%v\n", err)
+	}
+
+	err = db.WithTx(ctx, func(tx *Tx) error {
+
+		if _, err := tx.Exec(insert, "rolled_back"); err != nil {
+			return err
+		}
+		return fmt.Errorf("abort")
+	})
+	if err == nil || err.Error() != "abort" {
+		t.Fatalf("WithTx.#002 err:%v\n", err)
+	}
+
+	func() {
+		defer func() {
+			if p := recover(); p == nil {
+				t.Fatalf("WithTx.#003 expected panic")
+			}
+		}()
+
+		db.WithTx(ctx, func(tx *Tx) error {
+			tx.Exec(insert, "panicked")
+			panic("abort")
+		})
+	}()
+
+	if row, err := db.QueryRow(count); err != nil || row.Int("num") != 10 {
+		t.Fatalf("WithTx.#004 row:%v err:%v\n", row, err)
+	}
+}
+
+func TestSqlite3Savepoint(t *testing.T) {
+
+	db, err := New(Config{
+		Driver:      "sqlite3",
+		Addr:        ":memory:",
+		MaxIdleConn: 1,
+		MaxConn:     1,
+	})
+	if err != nil {
+		t.Fatalf("db conn err:%s", err.Error())
+	}
+	defer db.Close()
+
+	if _, err = db.ExecString("create table sp(id integer not null primary key autoincrement, name text)"); err != nil {
+		t.Fatalf("Savepoint.#000 err:%v\n", err)
+	}
+
+	var (
+		ctx    = context.Background()
+		insert = NewQuerySet().InsertTable("sp").InsertFields("name").InsertValues("(?)")
+	)
+
+	err = db.WithTx(ctx, func(tx *Tx) error {
+
+		if _, err := tx.Exec(insert, "outer"); err != nil {
+			return err
+		}
+
+		if err := tx.Savepoint("manual"); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(insert, "manual_undone"); err != nil {
+			return err
+		}
+		if err := tx.RollbackTo("manual"); err != nil {
+			return err
+		}
+		if err := tx.Release("manual"); err != nil {
+			return err
+		}
+
+		if err := tx.Savepoint("bad name"); err == nil {
+			return fmt.Errorf("expected invalid savepoint error")
+		}
+
+		err := tx.WithTx(ctx, func(tx *Tx) error {
+
+			if _, err := tx.Exec(insert, "nested_kept"); err != nil {
+				return err
+			}
+
+			err := tx.WithTx(ctx, func(tx *Tx) error {
+				tx.Exec(insert, "nested_undone")
+				return fmt.Errorf("abort inner")
+			})
+			if err == nil || err.Error() != "abort inner" {
+				return fmt.Errorf("nested err:%v", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.WithTx(ctx, func(tx *Tx) error {
+			_, err := tx.Exec(insert, "nested_released")
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatalf("Savepoint.#001 err:%v\n", err)
+	}
+
+	rst, err := db.Query(NewQuerySet().Select("name").From("sp").OrderBy("id"))
+	if err != nil {
+		t.Fatalf("Savepoint.#002 err:%v\n", err)
+	}
+
+	var names []string
+	for _, row := range rst.Data {
+		names = append(names, row.Get("name"))
+	}
+
+	if strings.Join(names, ",") != "outer,nested_kept,nested_released" {
+		t.Fatalf("Savepoint.#002 names:%v\n", names)
+	}
+}
+
+func TestSqlite3WithTxRetry(t *testing.T) {
+
+	db, err := New(Config{
+		Driver:      "sqlite3",
+		Addr:        "file:" + filepath.Join(t.TempDir(), "retry.db") + "?_busy_timeout=0",
+		MaxIdleConn: 2,
+		MaxConn:     2,
+	})
+	if err != nil {
+		t.Fatalf("db conn err:%s", err.Error())
+	}
+	defer db.Close()
+
+	if _, err = db.ExecString("create table retry(id integer not null primary key autoincrement, name text)"); err != nil {
+		t.Fatalf("Retry.#000 err:%v\n", err)
+	}
+
+	var (
+		ctx    = context.Background()
+		insert = NewQuerySet().InsertTable("retry").InsertFields("name").InsertValues("(?)")
+	)
+
+	blocker, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Retry.#001 err:%v\n", err)
+	}
+
+	if _, err = blocker.Exec(insert, "blocker"); err != nil {
+		t.Fatalf("Retry.#001 err:%v\n", err)
+	}
+
+	attempts := 0
+	policy := RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  time.Millisecond,
+		Retryable: func(err error) bool {
+
+			if attempts == 1 {
+				blocker.Commit()
+			}
+			return IsRetryable(err)
+		},
+	}
+
+	err = db.WithTxRetry(ctx, policy, func(tx *Tx) error {
+		attempts++
+		_, err := tx.Exec(insert, "retried")
+		return err
+	})
+	if err != nil || attempts != 2 {
+		t.Fatalf("Retry.#002 attempts:%d err:%v\n", attempts, err)
+	}
+
+	attempts = 0
+	err = db.WithTxRetry(ctx, RetryPolicy{MaxAttempts: 5}, func(tx *Tx) error {
+		attempts++
+		return fmt.Errorf("not retryable")
+	})
+	if err == nil || attempts != 1 {
+		t.Fatalf("Retry.#003 attempts:%d err:%v\n", attempts, err)
+	}
+}
+
+func TestSqlite3Errors(t *testing.T) {
+
+	db, err := New(Config{
+		Driver:      "sqlite3",
+		Addr:        ":memory:",
+		MaxIdleConn: 1,
+		MaxConn:     1,
+	})
+	if err != nil {
+		t.Fatalf("db conn err:%s", err.Error())
+	}
+	defer db.Close()
+
+	for _, stmt := range []string{
+		"pragma foreign_keys = on",
+		"create table parent(id integer not null primary key, name text not null unique)",
+		"create table child(id integer not null primary key, parent_id integer not null references parent(id))",
+		"insert into parent(id, name) values(1, 'p1')",
+	} {
+		if _, err = db.ExecString(stmt); err != nil {
+			t.Fatalf("Errors.#000 err:%v\n", err)
+		}
+	}
+
+	for stmt, need := range map[string]ErrorKind{
+		"insert into parent(id, name) values(2, 'p1')":  KindDuplicateKey,
+		"insert into parent(id, name) values(1, 'p2')":  KindDuplicateKey,
+		"insert into parent(id, name) values(3, null)":  KindNotNull,
+		"insert into child(id, parent_id) values(1, 9)": KindForeignKey,
+		"insert into parent values values":              KindSyntax,
+		"insert into missing(id) values(1)":             KindUnknown,
+	} {
+
+		if _, err = db.ExecString(stmt); Classify(err) != need {
+			t.Errorf("Errors.#001 %s: kind:%s err:%v", stmt, Classify(err), err)
+		}
+	}
+
+	qset := NewQuerySet().Select("*").From("parent").Where("id").Eq(9)
+
+	if _, err = db.QueryRow(qset); !errors.Is(err, ErrNoRows) {
+		t.Errorf("Errors.#002 err:%v", err)
+	}
+
+	if _, err = db.TxExec(qset); !errors.Is(err, ErrNoTransaction) {
+		t.Errorf("Errors.#002 err:%v", err)
+	}
+
+	if err = db.TxBegin(qset); err != nil {
+		t.Fatalf("Errors.#003 err:%v\n", err)
+	}
+	defer db.TxRollBack(qset)
+
+	if _, err = db.TxStmtExec(qset); !errors.Is(err, ErrNoStatement) || errors.Is(err, ErrNoTransaction) || err.Error() != "Client Error" {
+		t.Errorf("Errors.#003 err:%v", err)
+	}
+
+	if _, err = db.PrepareQuery(NewQuerySet().Select("*").From("parent")); !errors.Is(err, ErrNoArgs) {
+		t.Errorf("Errors.#003 err:%v", err)
+	}
+}
+
+func TestSqlite3Join(t *testing.T) {
+
+	db, err := New(Config{
+		Driver:      "sqlite3",
+		Addr:        ":memory:",
+		MaxIdleConn: 1,
+		MaxConn:     1,
+	})
+	if err != nil {
+		t.Fatalf("db conn err:%s", err.Error())
+	}
+	defer db.Close()
+
+	for _, stmt := range []string{
+		"create table users(id integer not null primary key, name text)",
+		"create table orders(id integer not null primary key, user_id integer, status integer)",
+		"create table profiles(user_id integer not null primary key, city text)",
+		"insert into users values(1, 'alice'), (2, 'bob')",
+		"insert into orders values(1, 1, 1), (2, 1, 1), (3, 2, 0)",
+		"insert into profiles values(1, 'paris'), (2, 'rome')",
+	} {
+		if _, err = db.ExecString(stmt); err != nil {
+			t.Fatalf("Join.#000 err:%v\n", err)
+		}
+	}
+
+	var (
+		sub  = NewQuerySet().Select("user_id, COUNT(*) AS num").From("orders").Where("status").Eq(1).GroupBy("user_id")
+		qset = NewQuerySet().Select("u.name, p.city, o.num").FromAs("users", "u").
+			InnerJoinAsOn("profiles", "p", "p.user_id = u.id").
+			Join(JoinLeft, sub, "o", "o.user_id = u.id").
+			OrderBy("u.id")
+	)
+
+	rst, err := db.Query(qset)
+	if err != nil {
+		t.Fatalf("Join.#001 err:%v\n", err)
+	}
+
+	if len(rst.Data) != 2 || rst.Data[0].Get("city") != "paris" || rst.Data[0].Int("num") != 2 || rst.Data[1].Get("num") != "NULL" {
+		t.Fatalf("Join.#001 rst:%v\n", rst.Data)
+	}
+}
+
+func TestSqlite3Subquery(t *testing.T) {
+
+	db, err := New(Config{
+		Driver:      "sqlite3",
+		Addr:        ":memory:",
+		MaxIdleConn: 1,
+		MaxConn:     1,
+	})
+	if err != nil {
+		t.Fatalf("db conn err:%s", err.Error())
+	}
+	defer db.Close()
+
+	for _, stmt := range []string{
+		"create table users(id integer not null primary key, name text)",
+		"create table orders(id integer not null primary key, user_id integer, status integer)",
+		"insert into users values(1, 'alice'), (2, 'bob'), (3, 'carol')",
+		"insert into orders values(1, 1, 1), (2, 1, 1), (3, 2, 0)",
+	} {
+		if _, err = db.ExecString(stmt); err != nil {
+			t.Fatalf("Subquery.#000 err:%v\n", err)
+		}
+	}
+
+	var (
+		paid   = NewQuerySet().Select("user_id").From("orders").Where("status").Eq(1)
+		orders = NewQuerySet().Select("id").From("orders").Where(Expr("orders.user_id = users.id"))
+		count  = NewQuerySet().Select("COUNT(*)").From("orders").Where(Expr("orders.user_id = users.id"))
+	)
+
+	rst, err := db.Query(NewQuerySet().Select("name").SelectSub(count, "num").From("users").Where("id").In(paid))
+	if err != nil {
+		t.Fatalf("Subquery.#001 err:%v\n", err)
+	}
+
+	if len(rst.Data) != 1 || rst.Data[0].Get("name") != "alice" || rst.Data[0].Int("num") != 2 {
+		t.Fatalf("Subquery.#001 rst:%v\n", rst.Data)
+	}
+
+	rst, err = db.Query(NewQuerySet().Select("name").From("users").Where(NotExists(orders)).Or(Eq("id", NewQuerySet().Select("MIN(id)").From("users"))).OrderBy("id"))
+	if err != nil {
+		t.Fatalf("Subquery.#002 err:%v\n", err)
+	}
+
+	if len(rst.Data) != 2 || rst.Data[0].Get("name") != "alice" || rst.Data[1].Get("name") != "carol" {
+		t.Fatalf("Subquery.#002 rst:%v\n", rst.Data)
+	}
+
+	row, err := db.QueryRow(NewQuerySet().Select("COUNT(*) AS num").FromAs(NewQuerySet().Select("user_id").From("orders").Where("status").Eq(Param).GroupBy("user_id"), "t"), 1)
+	if err != nil {
+		t.Fatalf("Subquery.#003 err:%v\n", err)
+	}
+
+	if row.Int("num") != 1 {
+		t.Fatalf("Subquery.#003 num:%v\n", row.Get("num"))
+	}
+}
+
+func TestSqlite3Cte(t *testing.T) {
+
+	db, err := New(Config{
+		Driver:      "sqlite3",
+		Addr:        ":memory:",
+		MaxIdleConn: 1,
+		MaxConn:     1,
+	})
+	if err != nil {
+		t.Fatalf("db conn err:%s", err.Error())
+	}
+	defer db.Close()
+
+	for _, stmt := range []string{
+		"create table categories(id integer not null primary key, parent_id integer, name text)",
+		"insert into categories values(1, null, 'root'), (2, 1, 'books'), (3, 2, 'novels'), (4, 1, 'music'), (5, null, 'other')",
+	} {
+		if _, err = db.ExecString(stmt); err != nil {
+			t.Fatalf("Cte.#000 err:%v\n", err)
+		}
+	}
+
+	// The anchor row, with the recursive member joined to it by UnionAll.
+	tree := NewQuerySet().Select("id, name, 0").From("categories").Where("id").Eq(Param).
+		UnionAll(NewQuerySet().Select("c.id, c.name, t.depth + 1").FromAs("categories", "c").InnerJoinAsOn("tree", "t", "c.parent_id = t.id"))
+
+	qset := NewQuerySet().WithRecursive("tree(id, name, depth)", tree).
+		Select("name, depth").From("tree").OrderBy("depth, id")
+
+	rst, err := db.Query(qset, 1)
+	if err != nil {
+		t.Fatalf("Cte.#001 err:%v\n", err)
+	}
+
+	if len(rst.Data) != 4 || rst.Data[0].Get("name") != "root" || rst.Data[3].Get("name") != "novels" || rst.Data[3].Int("depth") != 2 {
+		t.Fatalf("Cte.#001 rst:%v\n", rst.Data)
+	}
+
+	res, err := db.Exec(NewQuerySet().WithRecursive("tree(id, name, depth)", tree).
+		UpdateTable("categories").UpdateSet("name = upper(name)").
+		Where("id").In(NewQuerySet().Select("id").From("tree").Where("depth").Gt(0)), 2)
+	if err != nil {
+		t.Fatalf("Cte.#002 err:%v\n", err)
+	}
+
+	if n, _ := res.RowsAffected(); n != 1 {
+		t.Fatalf("Cte.#002 affected:%d\n", n)
+	}
+}
+
+func TestSqlite3SetOp(t *testing.T) {
+
+	db, err := New(Config{
+		Driver:      "sqlite3",
+		Addr:        ":memory:",
+		MaxIdleConn: 1,
+		MaxConn:     1,
+	})
+	if err != nil {
+		t.Fatalf("db conn err:%s", err.Error())
+	}
+	defer db.Close()
+
+	for _, stmt := range []string{
+		"create table users(id integer not null primary key, name text)",
+		"create table admins(id integer not null primary key, name text)",
+		"create table categories(id integer not null primary key, parent_id integer, name text)",
+		"insert into users values(1, 'alice'), (2, 'bob'), (3, 'carol')",
+		"insert into admins values(2, 'bob'), (4, 'dave')",
+		"insert into categories values(1, null, 'root'), (2, 1, 'books'), (3, 2, 'novels'), (4, 1, 'music')",
+	} {
+		if _, err = db.ExecString(stmt); err != nil {
+			t.Fatalf("SetOp.#000 err:%v\n", err)
+		}
+	}
+
+	names := func(rst *Result) string {
+
+		var s []string
+		for _, v := range rst.Data {
+			s = append(s, v.Get("name"))
+		}
+		return strings.Join(s, ",")
+	}
+
+	for i, v := range []struct {
+		qset *QuerySet
+		need string
+	}{
+		{NewQuerySet().Select("name").From("users").UnionAll(NewQuerySet().Select("name").From("admins")).OrderBy("name DESC").Limit(1, 3), "carol,bob,bob"},
+		{NewQuerySet().Select("name").From("users").Union(NewQuerySet().Select("name").From("admins")).OrderBy("name"), "alice,bob,carol,dave"},
+		{NewQuerySet().Select("name").From("users").Intersect(NewQuerySet().Select("name").From("admins")), "bob"},
+		{NewQuerySet().Select("name").From("users").Where("id").Neq(1).Except(NewQuerySet().Select("name").From("admins")), "carol"},
+	} {
+
+		rst, err := db.Query(v.qset)
+		if err != nil {
+			t.Fatalf("SetOp.#%03d err:%v\n", i+1, err)
+		}
+
+		if got := names(rst); got != v.need {
+			t.Fatalf("SetOp.#%03d names:%s\n", i+1, got)
+		}
+	}
+
+	tree := NewQuerySet().Select("id, name").From("categories").Where("id").Eq(2).
+		UnionAll(NewQuerySet().Select("c.id, c.name").FromAs("categories", "c").InnerJoinAsOn("tree", "t", "c.parent_id = t.id"))
+
+	rst, err := db.Query(NewQuerySet().WithRecursive("tree(id, name)", tree).Select("name").From("tree").OrderBy("id"))
+	if err != nil {
+		t.Fatalf("SetOp.#005 err:%v\n", err)
+	}
+
+	if got := names(rst); got != "books,novels" {
+		t.Fatalf("SetOp.#005 names:%s\n", got)
+	}
+}
+
+func TestSqlite3Columns(t *testing.T) {
+
+	db, err := New(Config{
+		Driver:      "sqlite3",
+		Addr:        ":memory:",
+		MaxIdleConn: 1,
+		MaxConn:     1,
+	})
+	if err != nil {
+		t.Fatalf("db conn err:%s", err.Error())
+	}
+	defer db.Close()
+
+	if _, err = db.ExecString("create table items(id integer not null primary key, name text, score real, comment text default 'none')"); err != nil {
+		t.Fatalf("Columns.#000 err:%v\n", err)
+	}
+
+	score := 1.5
+	for i, item := range []*scanItem{
+		{scanBase: scanBase{ID: 1}, Name: "a", Score: &score, Ignored: "x"},
+		{scanBase: scanBase{ID: 2}, Name: "b"},
+	} {
+		if _, err = db.Exec(NewQuerySet().InsertTable("items").InsertStruct(item, SkipZero())); err != nil {
+			t.Fatalf("Columns.#%03d err:%v\n", i+1, err)
+		}
+	}
+
+	upd := scanItem{Name: "c", Comment: sql.NullString{String: "set", Valid: true}}
+	if _, err = db.Exec(NewQuerySet().UpdateTable("items").UpdateStruct(upd, Omit("id", "score")).Where("id").Eq(2)); err != nil {
+		t.Fatalf("Columns.#003 err:%v\n", err)
+	}
+
+	var items []scanItem
+	if err = db.QueryInto(NewQuerySet().Select("id, name, score, comment").From("items").OrderBy("id"), &items); err != nil {
+		t.Fatalf("Columns.#004 err:%v\n", err)
+	}
+
+	if len(items) != 2 || items[0].Comment.String != "none" || items[0].Score == nil || *items[0].Score != 1.5 ||
+		items[1].Name != "c" || items[1].Comment.String != "set" || items[1].Score != nil {
+		t.Fatalf("Columns.#004 items:%+v\n", items)
+	}
+
+	// Column data is bound as it is, "?" included, and never left open.
+	if _, err = db.Exec(NewQuerySet().InsertTable("items").InsertStruct(scanItem{scanBase: scanBase{ID: 3}, Name: "?"})); err != nil {
+		t.Fatalf("Columns.#005 err:%v\n", err)
+	}
+
+	if _, err = db.Exec(NewQuerySet().UpdateTable("items").UpdateMap(map[string]interface{}{"comment": "?"}).Where("id").Eq(3)); err != nil {
+		t.Fatalf("Columns.#006 err:%v\n", err)
+	}
+
+	rst, err := db.Query(NewQuerySet().Select("name, comment").From("items").Where("id").Eq(3))
+	if err != nil || len(rst.Data) != 1 || rst.Data[0].Get("name") != "?" || rst.Data[0].Get("comment") != "?" {
+		t.Fatalf("Columns.#007 rst:%v err:%v\n", rst, err)
+	}
+
+	if _, _, err = NewQuerySet().InsertTable("items").InsertRow(map[string]interface{}{"name": Param}).Build("x"); err == nil {
+		t.Fatalf("Columns.#008 err:%v\n", err)
+	}
+}
+
+func TestSqlite3BatchInsert(t *testing.T) {
+
+	db, err := New(Config{
+		Driver:      "sqlite3",
+		Addr:        ":memory:",
+		MaxIdleConn: 1,
+		MaxConn:     1,
+	})
+	if err != nil {
+		t.Fatalf("db conn err:%s", err.Error())
+	}
+	defer db.Close()
+
+	if _, err = db.ExecString("create table foo(id integer not null primary key, title text)"); err != nil {
+		t.Fatalf("BatchInsert.#000 err:%v\n", err)
+	}
+
+	var rows [][]interface{}
+	for i := 1; i <= 1000; i++ {
+		rows = append(rows, []interface{}{i, fmt.Sprintf("title%d?", i)})
+	}
+
+	// 999 variables hold 499 rows of two columns.
+	rst, err := db.BatchInsert("foo", []string{"id", "title"}, rows)
+	if err != nil || rst.RowsAffected != 1000 || rst.Chunks != 3 {
+		t.Fatalf("BatchInsert.#001 rst:%+v err:%v\n", rst, err)
+	}
+
+	row, err := db.QueryRow(NewQuerySet().Select("title").From("foo").Where("id").Eq(7))
+	if err != nil || row.Get("title") != "title7?" {
+		t.Fatalf("BatchInsert.#001 row:%v err:%v\n", row, err)
+	}
+
+	// Rows 1001-1010 are new, 995-1000 collide in the second chunk.
+	rows = rows[:0]
+	for i := 1001; i <= 1010; i++ {
+		rows = append(rows, []interface{}{i, "new"})
+	}
+	for i := 995; i <= 1000; i++ {
+		rows = append(rows, []interface{}{i, "dup"})
+	}
+
+	rst, err = db.BatchInsertOptions(context.Background(), BatchOptions{MaxRows: 10, Tx: true}, "foo", []string{"id", "title"}, rows)
+
+	var cerr *ChunkError
+	if !errors.As(err, &cerr) || cerr.Chunk != 1 || cerr.Offset != 10 || rst.RowsAffected != 0 || Classify(err) != KindDuplicateKey {
+		t.Fatalf("BatchInsert.#002 rst:%+v err:%v\n", rst, err)
+	}
+
+	rst, err = db.BatchInsertOptions(context.Background(), BatchOptions{MaxRows: 10}, "foo", []string{"id", "title"}, rows)
+	if err == nil || len(rst.Errors) != 1 || rst.RowsAffected != 10 || rst.Chunks != 2 {
+		t.Fatalf("BatchInsert.#003 rst:%+v err:%v\n", rst, err)
+	}
+
+	if _, err = db.BatchInsert("foo", []string{"id", "title"}, [][]interface{}{{1}}); err == nil {
+		t.Fatalf("BatchInsert.#004 err:%v\n", err)
+	}
+}
+
+func TestSqlite3Load(t *testing.T) {
+
+	db, err := New(Config{
+		Driver:      "sqlite3",
+		Addr:        ":memory:",
+		MaxIdleConn: 1,
+		MaxConn:     1,
+	})
+	if err != nil {
+		t.Fatalf("db conn err:%s", err.Error())
+	}
+	defer db.Close()
+
+	if _, err = db.ExecString("create table foo(id integer not null primary key, title text, note text)"); err != nil {
+		t.Fatalf("Load.#000 err:%v\n", err)
+	}
+
+	csvData := "id,skip,title\n1,x,plain\n2,y,\"with, comma\"\n3,z,\"say \"\"hi\"\"\"\n"
+
+	rst, err := db.Load(strings.NewReader(csvData), LoadOptions{
+		Table:   "foo",
+		Columns: []string{"id", "-", "title"},
+		Header:  true,
+		Batch:   BatchOptions{MaxRows: 2, Tx: true},
+	})
+	if err != nil || rst.Rows != 3 || rst.Bytes != int64(len(csvData)) || rst.Batch.Chunks != 2 {
+		t.Fatalf("Load.#001 rst:%+v err:%v\n", rst, err)
+	}
+
+	tsvData := "4\ttab\\there\t\\N\n5\tline\\\nbreak\tnote\n"
+
+	rst, err = db.Load(strings.NewReader(tsvData), LoadOptions{
+		Table:   "foo",
+		Columns: []string{"id", "title", "note"},
+		Format:  LoadTSV,
+	})
+	if err != nil || rst.Rows != 2 {
+		t.Fatalf("Load.#002 rst:%+v err:%v\n", rst, err)
+	}
+
+	res, err := db.Query(NewQuerySet().Select("id, title, note").From("foo").OrderBy("id"))
+	if err != nil || len(res.Data) != 5 {
+		t.Fatalf("Load.#003 rst:%v err:%v\n", res, err)
+	}
+
+	for i, need := range []string{"plain", "with, comma", `say "hi"`, "tab\there", "line\nbreak"} {
+		if res.Data[i].Get("title") != need {
+			t.Fatalf("Load.#%03d title:%q\n", i+4, res.Data[i].Get("title"))
+		}
+	}
+
+	if res.Data[3].Get("note") != "NULL" || res.Data[4].Get("note") != "note" {
+		t.Fatalf("Load.#009 rst:%v\n", res.Data)
+	}
+
+	_, err = db.Load(strings.NewReader("6,a\n7\n"), LoadOptions{Table: "foo", Columns: []string{"id", "title"}})
+	if err == nil {
+		t.Fatalf("Load.#010 err:%v\n", err)
+	}
+
+	// CRLF lines leave no \r in the last field, as on mysql.
+	crlfData := "id,title\r\n8,crlf\r\n"
+
+	rst, err = db.Load(strings.NewReader(crlfData), LoadOptions{Table: "foo", Columns: []string{"id", "title"}, Header: true})
+	if err != nil || rst.Rows != 1 || rst.Bytes != int64(len(crlfData)) {
+		t.Fatalf("Load.#011 rst:%+v err:%v\n", rst, err)
+	}
+
+	row, err := db.QueryRow(NewQuerySet().Select("title").From("foo").Where("id").Eq(8))
+	if err != nil || row.Get("title") != "crlf" {
+		t.Fatalf("Load.#011 row:%v err:%v\n", row, err)
+	}
+}
+
+func TestSqlite3QueryIter(t *testing.T) {
+
+	db, err := New(Config{
+		Driver:      "sqlite3",
+		Addr:        ":memory:",
+		MaxIdleConn: 1,
+		MaxConn:     1,
+	})
+	if err != nil {
+		t.Fatalf("db conn err:%s", err.Error())
+	}
+	defer db.Close()
+
+	if _, err = db.ExecString("create table foo(id integer not null primary key, title text)"); err != nil {
+		t.Fatalf("QueryIter.#000 err:%v\n", err)
+	}
+
+	if _, err = db.ExecString("insert into foo values(1, 'a'), (2, null), (3, 'c')"); err != nil {
+		t.Fatalf("QueryIter.#000 err:%v\n", err)
+	}
+
+	it, err := db.QueryIter(NewQuerySet().Select("id, title").From("foo").Where("id").Gt(Param).OrderBy("id"), 1)
+	if err != nil {
+		t.Fatalf("QueryIter.#001 err:%v\n", err)
+	}
+
+	var titles []string
+	for it.Next() {
+		titles = append(titles, it.Row().Get("title"))
+	}
+
+	if it.Err() != nil || strings.Join(titles, ",") != "NULL,c" || it.Row() != nil {
+		t.Fatalf("QueryIter.#001 titles:%v err:%v\n", titles, it.Err())
+	}
+
+	// The iterator was closed by Next, so the single connection is free
+	// for the transaction.
+	stop := errors.New("stop")
+	err = db.WithTx(context.Background(), func(tx *Tx) error {
+
+		n := 0
+		err := tx.QueryEach(NewQuerySet().Select("id").From("foo").OrderBy("id"), func(row *RowColumn) error {
+
+			if n++; row.Int("id") == 2 {
+				return stop
+			}
+			return nil
+		})
+
+		if err != stop || n != 2 {
+			t.Fatalf("QueryIter.#002 n:%d err:%v\n", n, err)
+		}
+
+		_, err = tx.ExecString("delete from foo where id = 3")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("QueryIter.#003 err:%v\n", err)
+	}
+
+	n := 0
+	if err = db.QueryEach(NewQuerySet().Select("id").From("foo"), func(*RowColumn) error { n++; return nil }); err != nil || n != 2 {
+		t.Fatalf("QueryIter.#004 n:%d err:%v\n", n, err)
+	}
+}
+
+func TestSqlite3RowErrors(t *testing.T) {
+
+	var dbs [2]*Server
+	for i := range dbs {
+
+		db, err := New(Config{
+			Driver:      "sqlite3",
+			Addr:        ":memory:",
+			MaxIdleConn: 1,
+			MaxConn:     1,
+			LenientRows: i == 1,
+		})
+		if err != nil {
+			t.Fatalf("db conn err:%s", err.Error())
+		}
+		defer db.Close()
+
+		for _, stmt := range []string{
+			"create table foo(id integer not null primary key)",
+			"insert into foo values(1), (2), (3), (4)",
+		} {
+			if _, err = db.ExecString(stmt); err != nil {
+				t.Fatalf("RowErrors.#000 err:%v\n", err)
+			}
+		}
+
+		dbs[i] = db
+	}
+
+	// The overflow fails the step to the third row, ending the result in
+	// both modes with the rows before it.
+	var (
+		rerr *RowError
+		qset = NewQuerySet().Select("CASE WHEN id = 3 THEN abs(-9223372036854775807 - 1) ELSE id END AS id").From("foo")
+	)
+
+	for i, db := range dbs {
+
+		rst, err := db.Query(qset)
+		if !errors.As(err, &rerr) || rerr.Index != 2 || len(rst.Data) != 2 {
+			t.Fatalf("RowErrors.#%03d rst:%v err:%v\n", i+1, rst, err)
+		}
+	}
+
+	it, err := dbs[0].QueryIter(qset)
+	if err != nil {
+		t.Fatalf("RowErrors.#003 err:%v\n", err)
+	}
+
+	for it.Next() {
+	}
+
+	if !errors.As(it.Err(), &rerr) || rerr.Index != 2 {
+		t.Fatalf("RowErrors.#003 err:%v\n", it.Err())
+	}
+}
+
+func TestSqlite3ResultColumns(t *testing.T) {
+
+	db, err := New(Config{
+		Driver:      "sqlite3",
+		Addr:        ":memory:",
+		MaxIdleConn: 1,
+		MaxConn:     1,
+	})
+	if err != nil {
+		t.Fatalf("db conn err:%s", err.Error())
+	}
+	defer db.Close()
+
+	if _, err = db.ExecString("create table foo(id integer not null primary key, title varchar(32), price decimal(10,2))"); err != nil {
+		t.Fatalf("ResultColumns.#000 err:%v\n", err)
+	}
+
+	if _, err = db.ExecString("insert into foo values(1, 'a', 1.5), (2, null, 2)"); err != nil {
+		t.Fatalf("ResultColumns.#000 err:%v\n", err)
+	}
+
+	rst, err := db.Query(NewQuerySet().Select("title, id, price, id").From("foo").OrderBy("id"))
+	if err != nil {
+		t.Fatalf("ResultColumns.#001 err:%v\n", err)
+	}
+
+	if names := strings.Join(rst.ColumnNames(), ","); names != "title,id,price,id" {
+		t.Fatalf("ResultColumns.#001 names:%s\n", names)
+	}
+
+	if rst.Columns[0].DatabaseType != "varchar(32)" || rst.Columns[1].DatabaseType != "INTEGER" || rst.Columns[2].DatabaseType != "decimal(10,2)" {
+		t.Fatalf("ResultColumns.#002 columns:%+v %+v %+v\n", *rst.Columns[0], *rst.Columns[1], *rst.Columns[2])
+	}
+
+	if row := strings.Join(rst.Row(1), ","); row != "NULL,2,2,2" || rst.Row(2) != nil {
+		t.Fatalf("ResultColumns.#003 row:%s\n", row)
+	}
+
+	it, err := db.QueryIter(NewQuerySet().Select("id, title").From("foo").OrderBy("id"))
+	if err != nil {
+		t.Fatalf("ResultColumns.#004 err:%v\n", err)
+	}
+
+	var values []string
+	for it.Next() {
+		values = append(values, it.Values()...)
+	}
+
+	if it.Err() != nil || strings.Join(values, ",") != "1,a,2,NULL" {
+		t.Fatalf("ResultColumns.#004 values:%v err:%v\n", values, it.Err())
+	}
+}
+
+func TestSqlite3Seek(t *testing.T) {
+
+	db, err := New(Config{
+		Driver:      "sqlite3",
+		Addr:        ":memory:",
+		MaxIdleConn: 1,
+		MaxConn:     1,
+	})
+	if err != nil {
+		t.Fatalf("db conn err:%s", err.Error())
+	}
+	defer db.Close()
+
+	if _, err = db.ExecString("create table foo(id integer not null primary key, grp integer not null, title text)"); err != nil {
+		t.Fatalf("Seek.#000 err:%v\n", err)
+	}
+
+	if _, err = db.ExecString("insert into foo values(1, 2, 'a'), (2, 1, 'b'), (3, 2, 'c'), (4, 1, 'd'), (5, 3, 'e'), (6, 1, 'f'), (7, 9, 'g')"); err != nil {
+		t.Fatalf("Seek.#000 err:%v\n", err)
+	}
+
+	ids := func(p *KeysetPage) string {
+
+		var s []string
+		if p == nil {
+			return ""
+		}
+
+		for _, row := range p.Data {
+			s = append(s, row.Get("id"))
+		}
+		return strings.Join(s, ",")
+	}
+
+	var (
+		qset = NewQuerySet().Select("f.id, f.grp, f.title").FromAs("foo", "f").Where("f.id").Neq(Param)
+		k    = Keyset{Keys: []SortKey{{Column: "f.grp"}, {Column: "f.id"}}, Limit: 2, Secret: []byte("secret")}
+		want = []string{"2,4", "6,1", "3,5"}
+		page *KeysetPage
+	)
+
+	for i, cursor := 0, ""; i < len(want); i++ {
+
+		if page, err = db.Seek(qset, k, cursor, 7); err != nil || ids(page) != want[i] || (page.Prev == "") != (i == 0) {
+			t.Fatalf("Seek.#001 page:%d ids:%s err:%v\n", i, ids(page), err)
+		}
+		cursor = page.Next
+	}
+
+	if page.Next != "" {
+		t.Fatalf("Seek.#002 next:%s\n", page.Next)
+	}
+
+	for i, cursor := len(want)-2, page.Prev; i >= 0; i-- {
+
+		if page, err = db.Seek(qset, k, cursor, 7); err != nil || ids(page) != want[i] || page.Next == "" {
+			t.Fatalf("Seek.#003 page:%d ids:%s err:%v\n", i, ids(page), err)
+		}
+		cursor = page.Prev
+	}
+
+	if page.Prev != "" {
+		t.Fatalf("Seek.#004 prev:%s\n", page.Prev)
+	}
+
+	// Mixed directions, without a WHERE of its own.
+	mixed := Keyset{Keys: []SortKey{{Column: "grp", Desc: true}, {Column: "id"}}, Limit: 4, Secret: []byte("secret")}
+	if page, err = db.Seek(NewQuerySet().Select("id, grp").From("foo"), mixed, ""); err != nil || ids(page) != "7,5,1,3" {
+		t.Fatalf("Seek.#005 ids:%s err:%v\n", ids(page), err)
+	}
+
+	if page, err = db.Seek(NewQuerySet().Select("id, grp").From("foo"), mixed, page.Next); err != nil || ids(page) != "2,4,6" || page.Next != "" {
+		t.Fatalf("Seek.#006 ids:%s err:%v\n", ids(page), err)
+	}
+
+	// A cursor only opens the ordering that signed it.
+	if _, err = db.Seek(qset, k, page.Prev, 7); err != ErrInvalidCursor {
+		t.Fatalf("Seek.#007 err:%v\n", err)
+	}
+
+	tampered := []byte(page.Prev)
+	tampered[3] ^= 1
+	if _, err = db.Seek(qset, mixed, string(tampered)); err != ErrInvalidCursor {
+		t.Fatalf("Seek.#008 err:%v\n", err)
+	}
+
+	// An expression key has no column affinity, so its cursor value must
+	// be bound back as the integer it was read as, not as text.
+	var (
+		expr  = Keyset{Keys: []SortKey{{Column: "n2"}}, Limit: 3, Secret: []byte("secret")}
+		exprq = NewQuerySet().Select("id, id*1 AS n2").From("foo")
+	)
+
+	for i, cursor, want := 0, "", []string{"1,2,3", "4,5,6", "7"}; i < len(want); i++ {
+
+		if page, err = db.Seek(exprq, expr, cursor); err != nil || ids(page) != want[i] {
+			t.Fatalf("Seek.#009 page:%d ids:%s err:%v\n", i, ids(page), err)
+		}
+		cursor = page.Next
+	}
+
+	if page, err = db.Seek(exprq, expr, page.Prev); err != nil || ids(page) != "4,5,6" {
+		t.Fatalf("Seek.#010 ids:%s err:%v\n", ids(page), err)
+	}
+}
+
+func TestSqlite3Paginate(t *testing.T) {
+
+	db, err := New(Config{
+		Driver:      "sqlite3",
+		Addr:        ":memory:",
+		MaxIdleConn: 1,
+		MaxConn:     1,
+	})
+	if err != nil {
+		t.Fatalf("db conn err:%s", err.Error())
+	}
+	defer db.Close()
+
+	if _, err = db.ExecString("create table foo(id integer not null primary key, grp integer not null)"); err != nil {
+		t.Fatalf("Paginate.#000 err:%v\n", err)
+	}
+
+	if _, err = db.ExecString("insert into foo values(1, 1), (2, 1), (3, 2), (4, 2), (5, 3), (6, 4), (7, 4), (8, 5)"); err != nil {
+		t.Fatalf("Paginate.#000 err:%v\n", err)
+	}
+
+	qset := NewQuerySet().Select("id").From("foo").Where("id").Lt(Param).OrderBy("id DESC")
+
+	page, err := db.Paginate(qset, 2, 3, 8)
+	if err != nil || page.Total != 7 || page.Pages != 3 || !page.HasNext || !page.HasPrev || len(page.Data) != 3 || page.Data[0].Get("id") != "4" {
+		t.Fatalf("Paginate.#001 page:%+v err:%v\n", page, err)
+	}
+
+	if page, err = db.Paginate(qset, 3, 3, 8); err != nil || page.HasNext || len(page.Data) != 1 || page.Data[0].Get("id") != "1" {
+		t.Fatalf("Paginate.#002 page:%+v err:%v\n", page, err)
+	}
+
+	if page, err = db.Paginate(qset, 4, 3, 8); err != nil || page.HasNext || !page.HasPrev || len(page.Data) != 0 {
+		t.Fatalf("Paginate.#003 page:%+v err:%v\n", page, err)
+	}
+
+	group := NewQuerySet().Select("grp, COUNT(*) AS n").From("foo").GroupBy("grp").Having("COUNT(*) > ?").OrderBy("grp")
+	if page, err = db.Paginate(group, 1, 2, 1); err != nil || page.Total != 3 || page.Pages != 2 || len(page.Data) != 2 || page.Data[1].Get("grp") != "2" {
+		t.Fatalf("Paginate.#004 page:%+v err:%v\n", page, err)
+	}
+
+	if page, err = db.Paginate(NewQuerySet().Select("DISTINCT grp").From("foo"), 1, 10); err != nil || page.Total != 5 || page.HasNext || page.HasPrev {
+		t.Fatalf("Paginate.#005 page:%+v err:%v\n", page, err)
+	}
+
+	if _, err = db.Paginate(qset, 0, 3, 8); err == nil {
+		t.Fatalf("Paginate.#006 expected invalid page error")
+	}
+}
@@ -0,0 +1,357 @@
+// Copyright 2016 The Sqlcl Author. All Rights Reserved.
+//
+// -----------------------------------------------------
+
+package sqlcl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+)
+
+var savepointName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Tx is a transaction started by Server.Begin. Unlike the QuerySet based
+// TxBegin, any number of QuerySets can run inside one Tx.
+type Tx struct {
+	tx          *sql.Tx
+	dialect     Dialect
+	stmts       map[*sql.Stmt]bool
+	strictScan  bool
+	lenientRows bool
+	savepoints  int
+}
+
+func (s *Server) Begin() (*Tx, error) {
+	return s.BeginTx(context.Background(), nil)
+}
+
+func (s *Server) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+
+	tx, err := s.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tx{
+		tx:          tx,
+		dialect:     s.dialect,
+		stmts:       make(map[*sql.Stmt]bool),
+		strictScan:  s.strictScan,
+		lenientRows: s.lenientRows,
+	}, nil
+}
+
+// WithTx runs fn in a transaction, committing it if fn returns nil and
+// rolling it back if fn returns an error or panics.
+func (s *Server) WithTx(ctx context.Context, fn func(*Tx) error) error {
+	return s.WithTxOptions(ctx, nil, fn)
+}
+
+func (s *Server) WithTxOptions(ctx context.Context, opts *sql.TxOptions, fn func(*Tx) error) error {
+
+	tx, err := s.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (t *Tx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *Tx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// Savepoint, RollbackTo and Release use the SAVEPOINT statements shared
+// by mysql and sqlite3. name must be a plain identifier.
+func (t *Tx) Savepoint(name string) error {
+	return t.savepoint(context.Background(), "SAVEPOINT ", name)
+}
+
+func (t *Tx) RollbackTo(name string) error {
+	return t.savepoint(context.Background(), "ROLLBACK TO SAVEPOINT ", name)
+}
+
+func (t *Tx) Release(name string) error {
+	return t.savepoint(context.Background(), "RELEASE SAVEPOINT ", name)
+}
+
+func (t *Tx) savepoint(ctx context.Context, stmt, name string) error {
+
+	if !savepointName.MatchString(name) {
+		return fmt.Errorf("Invalid Savepoint:%s", name)
+	}
+
+	_, err := t.tx.ExecContext(ctx, stmt+name)
+	return err
+}
+
+// WithTx runs fn inside a savepoint of t, releasing it if fn returns nil
+// and rolling back to it if fn returns an error or panics. The outer
+// transaction stays open either way, so nested calls only undo their own
+// work.
+func (t *Tx) WithTx(ctx context.Context, fn func(*Tx) error) error {
+
+	t.savepoints++
+	name := fmt.Sprintf("sqlcl_sp_%d", t.savepoints)
+
+	if err := t.savepoint(ctx, "SAVEPOINT ", name); err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			t.savepoint(ctx, "ROLLBACK TO SAVEPOINT ", name)
+			t.savepoint(ctx, "RELEASE SAVEPOINT ", name)
+			panic(p)
+		}
+	}()
+
+	if err := fn(t); err != nil {
+		t.savepoint(ctx, "ROLLBACK TO SAVEPOINT ", name)
+		t.savepoint(ctx, "RELEASE SAVEPOINT ", name)
+		return err
+	}
+
+	return t.savepoint(ctx, "RELEASE SAVEPOINT ", name)
+}
+
+func (t *Tx) QueryString(sql string) (*Result, error) {
+	return t.QueryStringContext(context.Background(), sql)
+}
+
+func (t *Tx) QueryStringContext(ctx context.Context, sql string) (*Result, error) {
+
+	rows, err := t.tx.QueryContext(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseRows(rows, t.lenientRows)
+}
+
+func (t *Tx) Query(q *QuerySet, args ...interface{}) (*Result, error) {
+	return t.QueryContext(context.Background(), q, args...)
+}
+
+func (t *Tx) QueryContext(ctx context.Context, q *QuerySet, args ...interface{}) (*Result, error) {
+
+	sql, args, err := q.bind(t.dialect, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := t.tx.QueryContext(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseRows(rows, t.lenientRows)
+}
+
+func (t *Tx) QueryRow(q *QuerySet, args ...interface{}) (*RowColumn, error) {
+	return t.QueryRowContext(context.Background(), q, args...)
+}
+
+func (t *Tx) QueryRowContext(ctx context.Context, q *QuerySet, args ...interface{}) (*RowColumn, error) {
+
+	rst, err := t.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rst.Data) < 1 {
+		return nil, ErrNoRows
+	}
+
+	return rst.Data[0], nil
+}
+
+func (t *Tx) QueryTyped(q *QuerySet, args ...interface{}) (*TypedResult, error) {
+	return t.QueryTypedContext(context.Background(), q, args...)
+}
+
+func (t *Tx) QueryTypedContext(ctx context.Context, q *QuerySet, args ...interface{}) (*TypedResult, error) {
+
+	sql, args, err := q.bind(t.dialect, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := t.tx.QueryContext(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTypedRows(rows)
+}
+
+func (t *Tx) QueryInto(q *QuerySet, dest interface{}, args ...interface{}) error {
+	return t.QueryIntoContext(context.Background(), q, dest, args...)
+}
+
+func (t *Tx) QueryIntoContext(ctx context.Context, q *QuerySet, dest interface{}, args ...interface{}) error {
+
+	sql, args, err := q.bind(t.dialect, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := t.tx.QueryContext(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+
+	return scanInto(rows, dest, t.strictScan)
+}
+
+func (t *Tx) Exec(q *QuerySet, args ...interface{}) (sql.Result, error) {
+	return t.ExecContext(context.Background(), q, args...)
+}
+
+func (t *Tx) ExecContext(ctx context.Context, q *QuerySet, args ...interface{}) (sql.Result, error) {
+
+	sql, args, err := q.bind(t.dialect, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.tx.ExecContext(ctx, sql, args...)
+}
+
+func (t *Tx) ExecString(sql string) (sql.Result, error) {
+	return t.ExecStringContext(context.Background(), sql)
+}
+
+func (t *Tx) ExecStringContext(ctx context.Context, sql string) (sql.Result, error) {
+	return t.tx.ExecContext(ctx, sql)
+}
+
+func (t *Tx) Prepare(q *QuerySet) error {
+	return t.PrepareContext(context.Background(), q)
+}
+
+func (t *Tx) PrepareContext(ctx context.Context, q *QuerySet) error {
+
+	sql, _, err := q.bind(t.dialect)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := t.tx.PrepareContext(ctx, sql)
+	if err != nil {
+		return err
+	}
+
+	q.stmt = stmt
+	t.stmts[stmt] = true
+
+	return nil
+}
+
+func (t *Tx) PrepareQuery(q *QuerySet, args ...interface{}) (*Result, error) {
+	return t.PrepareQueryContext(context.Background(), q, args...)
+}
+
+func (t *Tx) PrepareQueryContext(ctx context.Context, q *QuerySet, args ...interface{}) (*Result, error) {
+
+	stmt, err := t.stmt(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	_, args, err = q.bind(t.dialect, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseRows(rows, t.lenientRows)
+}
+
+func (t *Tx) PrepareQueryRow(q *QuerySet, args ...interface{}) (*RowColumn, error) {
+	return t.PrepareQueryRowContext(context.Background(), q, args...)
+}
+
+func (t *Tx) PrepareQueryRowContext(ctx context.Context, q *QuerySet, args ...interface{}) (*RowColumn, error) {
+
+	rst, err := t.PrepareQueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rst.Data) < 1 {
+		return nil, ErrNoRows
+	}
+
+	return rst.Data[0], nil
+}
+
+func (t *Tx) PrepareExec(q *QuerySet, args ...interface{}) (sql.Result, error) {
+	return t.PrepareExecContext(context.Background(), q, args...)
+}
+
+func (t *Tx) PrepareExecContext(ctx context.Context, q *QuerySet, args ...interface{}) (sql.Result, error) {
+
+	stmt, err := t.stmt(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	_, args, err = q.bind(t.dialect, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return stmt.ExecContext(ctx, args...)
+}
+
+func (t *Tx) PrepareClose(q *QuerySet) {
+
+	if q.stmt != nil {
+		delete(t.stmts, q.stmt)
+		q.stmt.Close()
+		q.stmt = nil
+	}
+}
+
+// stmt returns q's prepared statement bound to this transaction,
+// preparing it first when q has none. Statements prepared on the Server
+// are rebound with sql.Tx.Stmt.
+func (t *Tx) stmt(ctx context.Context, q *QuerySet) (*sql.Stmt, error) {
+
+	if q.stmt == nil {
+
+		if err := t.PrepareContext(ctx, q); err != nil {
+			return nil, err
+		}
+	}
+
+	if t.stmts[q.stmt] {
+		return q.stmt, nil
+	}
+
+	return t.tx.StmtContext(ctx, q.stmt), nil
+}
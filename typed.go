@@ -0,0 +1,389 @@
+// Copyright 2016 The Sqlcl Author. All Rights Reserved.
+//
+// -----------------------------------------------------
+
+package sqlcl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var typedTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02",
+}
+
+// TypedRow keeps column values as the driver returned them: int64,
+// uint64, float64, bool, string, []byte, time.Time, or nil for NULL.
+type TypedRow map[string]interface{}
+
+type TypedResult struct {
+	Data []*TypedRow
+}
+
+func (r *TypedRow) Has(k string) bool {
+	if r == nil {
+		return false
+	}
+
+	_, ok := (*r)[k]
+	return ok
+}
+
+func (r *TypedRow) IsNull(k string) bool {
+	if r == nil {
+		return true
+	}
+
+	v, ok := (*r)[k]
+	return !ok || v == nil
+}
+
+func (r *TypedRow) Value(k string) (interface{}, error) {
+	if r == nil {
+		return nil, fmt.Errorf("Column Not Found:%s", k)
+	}
+
+	v, ok := (*r)[k]
+	if !ok {
+		return nil, fmt.Errorf("Column Not Found:%s", k)
+	}
+	return v, nil
+}
+
+func (r *TypedRow) value(k string) (interface{}, error) {
+
+	v, err := r.Value(k)
+	if err != nil {
+		return nil, err
+	}
+
+	if v == nil {
+		return nil, fmt.Errorf("Null Value:%s", k)
+	}
+	return v, nil
+}
+
+func (r *TypedRow) Int64(k string) (int64, error) {
+
+	v, err := r.value(k)
+	if err != nil {
+		return 0, err
+	}
+
+	switch t := v.(type) {
+	case int64:
+		return t, nil
+	case uint64:
+		if t <= math.MaxInt64 {
+			return int64(t), nil
+		}
+	case bool:
+		if t {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		return strconv.ParseInt(t, 10, 64)
+	case []byte:
+		return strconv.ParseInt(string(t), 10, 64)
+	}
+
+	return 0, fmt.Errorf("Column %s: cannot convert %T to int64", k, v)
+}
+
+func (r *TypedRow) Uint64(k string) (uint64, error) {
+
+	v, err := r.value(k)
+	if err != nil {
+		return 0, err
+	}
+
+	switch t := v.(type) {
+	case uint64:
+		return t, nil
+	case int64:
+		if t >= 0 {
+			return uint64(t), nil
+		}
+	case string:
+		return strconv.ParseUint(t, 10, 64)
+	case []byte:
+		return strconv.ParseUint(string(t), 10, 64)
+	}
+
+	return 0, fmt.Errorf("Column %s: cannot convert %T to uint64", k, v)
+}
+
+func (r *TypedRow) Float64(k string) (float64, error) {
+
+	v, err := r.value(k)
+	if err != nil {
+		return 0, err
+	}
+
+	switch t := v.(type) {
+	case float64:
+		return t, nil
+	case float32:
+		return float64(t), nil
+	case int64:
+		return float64(t), nil
+	case uint64:
+		return float64(t), nil
+	case string:
+		return strconv.ParseFloat(t, 64)
+	case []byte:
+		return strconv.ParseFloat(string(t), 64)
+	}
+
+	return 0, fmt.Errorf("Column %s: cannot convert %T to float64", k, v)
+}
+
+func (r *TypedRow) Bool(k string) (bool, error) {
+
+	v, err := r.value(k)
+	if err != nil {
+		return false, err
+	}
+
+	switch t := v.(type) {
+	case bool:
+		return t, nil
+	case int64:
+		return t != 0, nil
+	case uint64:
+		return t != 0, nil
+	case string:
+		return strconv.ParseBool(t)
+	case []byte:
+		return strconv.ParseBool(string(t))
+	}
+
+	return false, fmt.Errorf("Column %s: cannot convert %T to bool", k, v)
+}
+
+func (r *TypedRow) String(k string) (string, error) {
+
+	v, err := r.value(k)
+	if err != nil {
+		return "", err
+	}
+
+	switch t := v.(type) {
+	case string:
+		return t, nil
+	case []byte:
+		return string(t), nil
+	case time.Time:
+		return t.Format(typedTimeLayouts[0]), nil
+	}
+
+	return fmt.Sprintf("%v", v), nil
+}
+
+func (r *TypedRow) Bytes(k string) ([]byte, error) {
+
+	v, err := r.value(k)
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := v.(type) {
+	case []byte:
+		return t, nil
+	case string:
+		return []byte(t), nil
+	}
+
+	return nil, fmt.Errorf("Column %s: cannot convert %T to []byte", k, v)
+}
+
+func (r *TypedRow) Time(k string) (time.Time, error) {
+
+	v, err := r.value(k)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case string:
+		return parseTypedTime(t)
+	case []byte:
+		return parseTypedTime(string(t))
+	}
+
+	return time.Time{}, fmt.Errorf("Column %s: cannot convert %T to time.Time", k, v)
+}
+
+func (s *Server) QueryTyped(q *QuerySet, args ...interface{}) (*TypedResult, error) {
+	return s.QueryTypedContext(context.Background(), q, args...)
+}
+
+func (s *Server) QueryTypedContext(ctx context.Context, q *QuerySet, args ...interface{}) (*TypedResult, error) {
+
+	sql, args, err := q.bind(s.dialect, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTypedRows(rows)
+}
+
+func (s *Server) QueryRowTyped(q *QuerySet, args ...interface{}) (*TypedRow, error) {
+	return s.QueryRowTypedContext(context.Background(), q, args...)
+}
+
+func (s *Server) QueryRowTypedContext(ctx context.Context, q *QuerySet, args ...interface{}) (*TypedRow, error) {
+
+	rst, err := s.QueryTypedContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rst.Data) < 1 {
+		return nil, ErrNoRows
+	}
+
+	return rst.Data[0], nil
+}
+
+func (s *Server) TxQueryTyped(q *QuerySet, args ...interface{}) (*TypedResult, error) {
+	return s.TxQueryTypedContext(context.Background(), q, args...)
+}
+
+func (s *Server) TxQueryTypedContext(ctx context.Context, q *QuerySet, args ...interface{}) (*TypedResult, error) {
+
+	if q.tx == nil {
+		return nil, ErrNoTransaction
+	}
+
+	sql, args, err := q.bind(s.dialect, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.tx.QueryContext(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTypedRows(rows)
+}
+
+func parseTypedRows(rows *sql.Rows) (*TypedResult, error) {
+
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		rst      = &TypedResult{}
+		values   = make([]interface{}, len(types))
+		row_dest = make([]interface{}, len(types))
+	)
+
+	for i := range values {
+		row_dest[i] = &values[i]
+	}
+
+	for rows.Next() {
+
+		if err := rows.Scan(row_dest...); err != nil {
+			return nil, err
+		}
+
+		rdt := &TypedRow{}
+
+		for i, col := range values {
+			(*rdt)[types[i].Name()] = typedValue(types[i], col)
+		}
+
+		rst.Data = append(rst.Data, rdt)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rst, nil
+}
+
+// typedValue converts the raw text some drivers return (mysql's text
+// protocol sends every column as []byte) into the Go type matching the
+// column's database type.
+func typedValue(ct *sql.ColumnType, v interface{}) interface{} {
+
+	b, ok := v.([]byte)
+	if !ok {
+		return v
+	}
+
+	name := strings.ToUpper(ct.DatabaseTypeName())
+	unsigned := strings.HasPrefix(name, "UNSIGNED ")
+	name = strings.TrimPrefix(name, "UNSIGNED ")
+
+	switch name {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT", "YEAR":
+		if unsigned {
+			if u, err := strconv.ParseUint(string(b), 10, 64); err == nil {
+				if u <= math.MaxInt64 {
+					return int64(u)
+				}
+				return u
+			}
+		} else if i, err := strconv.ParseInt(string(b), 10, 64); err == nil {
+			return i
+		}
+
+	case "FLOAT", "DOUBLE", "REAL":
+		if f, err := strconv.ParseFloat(string(b), 64); err == nil {
+			return f
+		}
+
+	case "BOOL", "BOOLEAN":
+		if t, err := strconv.ParseBool(string(b)); err == nil {
+			return t
+		}
+
+	case "DATE", "DATETIME", "TIMESTAMP":
+		if t, err := parseTypedTime(string(b)); err == nil {
+			return t
+		}
+
+	case "CHAR", "VARCHAR", "TEXT", "TINYTEXT", "MEDIUMTEXT", "LONGTEXT",
+		"ENUM", "SET", "JSON", "DECIMAL", "NUMERIC", "TIME":
+		return string(b)
+	}
+
+	return b
+}
+
+func parseTypedTime(s string) (time.Time, error) {
+
+	for _, layout := range typedTimeLayouts {
+
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("Invalid Time:%s", s)
+}